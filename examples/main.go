@@ -166,7 +166,6 @@ type Counter struct {
 	Changeset   *changeset.Changeset[Person]
 	First, Last string
 	Ticks       int
-	ticker      *time.Ticker
 }
 
 func (c *Counter) Mount(ctx context.Context, p live.Params) error {
@@ -175,14 +174,8 @@ func (c *Counter) Mount(ctx context.Context, p live.Params) error {
 	}
 	c.Changeset = changeset.New[Person](&cc, nil)
 	c.Ticks = 10
-	if c.ticker == nil {
-		c.ticker = time.NewTicker(time.Second)
-		go func() {
-			for range c.ticker.C {
-				live.SendInfo(ctx, &live.Info{Type: "tick"})
-			}
-		}()
-	}
+	// ticks until the view terminates; does nothing during the HTTP render
+	live.Every(ctx, time.Second, &live.Info{Type: "tick"})
 	return nil
 }
 
@@ -267,11 +260,6 @@ func (c *Counter) Render(ctx context.Context, meta *live.Meta) (any, *htmltmpl.T
 		`))
 }
 
-func (c *Counter) Close() error {
-	c.ticker.Stop()
-	return nil
-}
-
 type Nav struct {
 	Items map[string]string
 	Item  string
@@ -319,8 +307,8 @@ type MoreEvents struct {
 func (v *MoreEvents) HandleEvent(ctx context.Context, e *live.Event) error {
 	event := e.Type
 	// if event was a key event, use the key name as the event
-	if event == "key_update" {
-		event = e.Data.Get("key")
+	if e.Key != nil {
+		event = e.Key.Key
 	}
 	switch event {
 	case "up", "ArrowUp":
@@ -410,7 +410,15 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 		}
 	}()
 	defer s.pop(s.mark())
-	val, _ := indirect(s.evalPipeline(dot, r.Pipe))
+	val := s.evalPipeline(dot, r.Pipe)
+	if st, ok := streamer(val); ok {
+		items, payload := st.TreeStream()
+		if !s.pp {
+			s.tree.SetStream(payload)
+		}
+		val = reflect.ValueOf(items)
+	}
+	val, _ = indirect(val)
 	// mark top of stack before any variables in the body are pushed.
 	mark := s.mark()
 	oneIteration := func(index, elem reflect.Value) {
@@ -496,6 +504,15 @@ func (s *state) walkRange(dot reflect.Value, r *parse.RangeNode) {
 	}
 }
 
+// streamer returns the tmpl.Streamer held by v, if any.
+func streamer(v reflect.Value) (tmpl.Streamer, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	st, ok := v.Interface().(tmpl.Streamer)
+	return st, ok
+}
+
 func (s *state) walkTemplate(dot reflect.Value, t *parse.TemplateNode) {
 	s.at(t)
 	tmpl := s.tmpl.Lookup(t.Name)
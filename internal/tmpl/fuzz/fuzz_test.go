@@ -7,6 +7,11 @@ import (
 )
 
 func Fuzz(f *testing.F) {
+	f.Add(`{{ .I }}`)
+	f.Add(`a{{ if eq .I 1 }}{{ .I }}b{{ else }}c{{ .S }}{{ end }}d`)
+	f.Add(`{{ range .S }}<li>{{ . }}</li>{{ end }}{{ .I }}`)
+	f.Add(`{{ range $k, $v := .M }}{{ $k }}={{ if $v }}{{ $v }}{{ end }};{{ end }}`)
+	f.Add(`{{ with index .N 1 }}{{ range . }}{{ .B }}{{ end }}{{ end }}`)
 	f.Fuzz(func(t *testing.T, data string) {
 		fuzz(t.Fatalf, data)
 	})
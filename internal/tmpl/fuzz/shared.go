@@ -2,11 +2,14 @@ package fuzz
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
+	"reflect"
 	"strings"
 
 	"github.com/canopyclimate/golive/htmltmpl"
-	"github.com/canopyclimate/golive/internal/json"
+	gjson "github.com/canopyclimate/golive/internal/json"
+	"github.com/canopyclimate/golive/internal/tmpl"
 )
 
 var funcs = htmltmpl.FuncMap{
@@ -58,12 +61,12 @@ func fuzz(fatalf func(string, ...any), data string) int {
 
 	// Confirm that we can marshal it.
 	out, err := lt.JSON()
-	if err != nil && !errors.Is(err, json.ErrInvalidUTF8) {
+	if err != nil && !errors.Is(err, gjson.ErrInvalidUTF8) {
 		fatalf("failed to JSON: %v, template:\n%s\n", err, data)
 	}
 	// Confirm that a second marshalling generates the same output.
 	out2, err := lt.JSON()
-	if err != nil && !errors.Is(err, json.ErrInvalidUTF8) {
+	if err != nil && !errors.Is(err, gjson.ErrInvalidUTF8) {
 		fatalf("failed to JSON second time: %v, template:\n%s\n", err, data)
 	}
 	if !bytes.Equal(out, out2) {
@@ -87,5 +90,78 @@ func fuzz(fatalf func(string, ...any), data string) int {
 	if exec != render {
 		fatalf("exec != render: %q != %q", exec, render)
 	}
+
+	// Confirm that diffing against a tree rendered with other data
+	// and merging the result the way the client does yields this tree.
+	dot2 := map[string]any{
+		"I": 3,
+		"S": []string{"A", "C", "D"},
+		"M": map[string]int{"B": 4},
+		"N": []any{"Z", []any{}},
+	}
+	lt2, err := x.ExecuteTree(dot2)
+	if err != nil {
+		// Templates may legitimately fail with other data.
+		return 0
+	}
+	checkDiff(fatalf, data, lt2, lt)
+	checkDiff(fatalf, data, lt, lt2)
+	checkDiff(fatalf, data, lt, lt)
 	return 0
 }
+
+// checkDiff confirms that merging the diff between a and b into a yields b.
+func checkDiff(fatalf func(string, ...any), data string, a, b *tmpl.Tree) {
+	full, err := a.JSON()
+	if err != nil {
+		return
+	}
+	diff, err := tmpl.Diff(a, b)
+	if errors.Is(err, gjson.ErrInvalidUTF8) {
+		return
+	}
+	if err != nil {
+		fatalf("failed to diff: %v, template:\n%s\n", err, data)
+	}
+	want, err := b.JSON()
+	if err != nil {
+		fatalf("failed to JSON: %v, template:\n%s\n", err, data)
+	}
+	var rendered, d, w any
+	for _, x := range []struct {
+		b []byte
+		v *any
+	}{{full, &rendered}, {diff, &d}, {want, &w}} {
+		if err := json.Unmarshal(x.b, x.v); err != nil {
+			fatalf("invalid JSON %q: %v, template:\n%s\n", x.b, err, data)
+		}
+	}
+	got := merge(rendered, d)
+	if !reflect.DeepEqual(got, w) {
+		fatalf("merging diff %s into %s:\ngot  %v\nwant %v\ntemplate:\n%s\n", diff, full, got, w, data)
+	}
+}
+
+// merge merges the diff into rendered, mirroring the Phoenix LiveView client.
+func merge(rendered, diff any) any {
+	t, tok := rendered.(map[string]any)
+	d, dok := diff.(map[string]any)
+	if !tok || !dok {
+		return diff
+	}
+	if _, ok := d["s"]; ok {
+		return diff
+	}
+	for k, v := range d {
+		if vm, ok := v.(map[string]any); ok {
+			if _, ok := vm["s"]; !ok {
+				if _, ok := t[k].(map[string]any); ok {
+					t[k] = merge(t[k], v)
+					continue
+				}
+			}
+		}
+		t[k] = v
+	}
+	return t
+}
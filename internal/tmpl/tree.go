@@ -7,16 +7,31 @@ import (
 	"strconv"
 
 	"github.com/canopyclimate/golive/internal/json"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 type Tree struct {
 	Statics        []string
-	Dynamics       []any // string | *Tree | []any
+	Dynamics       []any // string | *Tree | []any | int (component ID)
 	ExcludeStatics bool  // controls if MarshalText Statics with serializing
 	Title          string
 	Events         [][]byte
+	Components     map[int]*Tree // component trees by component ID, only set on the root
+	Reply          []byte        // JSON reply to the event being handled, only set on the root
 	isRange        bool
 	rangeStep      int
+	stream         []byte // JSON stream payload of a range over a Streamer with changes
+}
+
+// A Streamer is a range value whose items the client inserts into, rather than
+// replaces, the items it already has (i.e. inside a phx-update="stream" element).
+// Ranging over a Streamer ranges over the items returned by TreeStream.
+type Streamer interface {
+	// TreeStream returns the items to range over and the JSON encoded
+	// [inserts, deleteIDs] stream payload for the client, or nil if
+	// the stream has no changes to send.
+	TreeStream() (items any, payload []byte)
 }
 
 func NewTree() *Tree {
@@ -57,6 +72,14 @@ func (t *Tree) AppendRangeSub() *Tree {
 	return sub
 }
 
+// SetStream records the stream payload of range tree t; see Streamer.
+func (t *Tree) SetStream(payload []byte) {
+	if t == nil {
+		return
+	}
+	t.stream = payload
+}
+
 // IncRangeStep records that a single range iteration has completed it.
 func (t *Tree) IncRangeStep() {
 	if t == nil {
@@ -116,8 +139,150 @@ func (t *Tree) Valid() error {
 	return nil
 }
 
-func Diff(a, b *Tree) {
-	panic("TODO")
+// Diff returns a JSON representation of the changes that turn a into b,
+// in the form the Phoenix LiveView client merges into its rendered state.
+// Unchanged dynamics are omitted, as are statics the client already has.
+// If a is nil, Diff returns the full JSON representation of b.
+func Diff(a, b *Tree) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	cw := &countWriter{w: buf}
+	if b.isString() {
+		writeStaticRoot(cw, a, b)
+	} else {
+		writeDiff(cw, a, b)
+	}
+	if cw.err != nil {
+		return nil, cw.err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeStaticRoot writes the diff between a and root tree b, which has no dynamics, to cw.
+// Unlike other trees, the root is always written as an object.
+func writeStaticRoot(cw *countWriter, a, b *Tree) {
+	cw.writeString(`{`)
+	n := 0
+	if a == nil || !a.isString() || a.Statics[0] != b.Statics[0] {
+		cw.writeString(`"s":[`)
+		cw.writeJSONString(b.Statics[0])
+		cw.writeString(`]`)
+		n++
+	}
+	b.writeRootMembers(cw, n)
+	cw.writeString(`}`)
+}
+
+// writeDiff writes the diff between a and b to cw.
+func writeDiff(cw *countWriter, a, b *Tree) {
+	if cw.err != nil {
+		return
+	}
+	if a == nil || !sameShape(a, b) {
+		// The client replaces anything that includes statics wholesale.
+		b.writeTo(cw)
+		return
+	}
+
+	cw.writeString(`{`)
+	n := 0
+	if !b.isRange {
+		for i, d := range b.Dynamics {
+			if equalDynamic(a.Dynamics[i], d) {
+				continue
+			}
+			cw.writeLeadingComma(n)
+			cw.writeString(`"`)
+			cw.writeInt(i)
+			cw.writeString(`":`)
+			cw.writeDynamicDiff(a.Dynamics[i], d)
+			n++
+		}
+	} else if b.stream != nil || !equalDynamics(a.Dynamics, b.Dynamics) {
+		// The client replaces comprehension dynamics wholesale,
+		// so every iteration must be sent in full.
+		// Streams are sent whenever they change, even if their items look the same.
+		cw.writeString(`"d":`)
+		b.writeRangeDynamics(cw)
+		b.writeStream(cw)
+		n++
+	}
+	var changed []int
+	for _, cid := range sortedKeys(b.Components) {
+		if prev, ok := a.Components[cid]; !ok || !equalDynamic(prev, b.Components[cid]) {
+			changed = append(changed, cid)
+		}
+	}
+	if len(changed) > 0 {
+		cw.writeLeadingComma(n)
+		cw.writeString(`"c":{`)
+		for i, cid := range changed {
+			cw.writeLeadingComma(i)
+			cw.writeString(`"`)
+			cw.writeInt(cid)
+			cw.writeString(`":`)
+			prev, c := a.Components[cid], b.Components[cid]
+			if prev == nil || !sameShape(prev, c) {
+				c.writeComponent(cw)
+				continue
+			}
+			writeDiff(cw, prev, c)
+		}
+		cw.writeString(`}`)
+		n++
+	}
+	b.writeRootMembers(cw, n)
+	cw.writeString(`}`)
+}
+
+// sortedKeys returns the keys of m in increasing order.
+func sortedKeys(m map[int]*Tree) []int {
+	keys := maps.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
+
+// sameShape reports whether a client holding a can be sent
+// b's dynamics without b's statics.
+func sameShape(a, b *Tree) bool {
+	// Trees without dynamics are serialized as plain strings,
+	// which the client cannot merge into.
+	if a.isString() || b.isString() {
+		return false
+	}
+	return a.isRange == b.isRange && slices.Equal(a.Statics, b.Statics)
+}
+
+// equalDynamic reports whether x and y would serialize identically.
+func equalDynamic(x, y any) bool {
+	switch x := x.(type) {
+	case string:
+		y, ok := y.(string)
+		return ok && x == y
+	case *Tree:
+		y, ok := y.(*Tree)
+		return ok && x.stream == nil && y.stream == nil && x.isRange == y.isRange &&
+			slices.Equal(x.Statics, y.Statics) &&
+			equalDynamics(x.Dynamics, y.Dynamics)
+	case []any:
+		y, ok := y.([]any)
+		return ok && equalDynamics(x, y)
+	case int:
+		y, ok := y.(int)
+		return ok && x == y
+	}
+	return false
+}
+
+func equalDynamics(x, y []any) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if !equalDynamic(x[i], y[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 // JSON returns a JSON representation of the tree.
@@ -212,13 +377,25 @@ func (cw *countWriter) writeDynamic(d any) {
 	case string:
 		cw.writeJSONString(d)
 	case *Tree:
-		// TODO - we want to include statics and diff them out elsewhere
 		d.writeTo(cw)
+	case int:
+		cw.writeInt(d)
 	default:
 		panic(fmt.Sprintf("unexpected type of Dynamic: %T, want string or *Tree, value is: %v", d, d))
 	}
 }
 
+// writeDynamicDiff writes the diff between dynamics x and y to cw.
+func (cw *countWriter) writeDynamicDiff(x, y any) {
+	xt, xok := x.(*Tree)
+	yt, yok := y.(*Tree)
+	if xok && yok {
+		writeDiff(cw, xt, yt)
+		return
+	}
+	cw.writeDynamic(y)
+}
+
 // WriteTo writes a JSON representation of the tree to w.
 func (t *Tree) WriteTo(w io.Writer) (written int64, err error) {
 	cw := &countWriter{w: w}
@@ -231,7 +408,7 @@ func (t *Tree) writeTo(cw *countWriter) {
 		return
 	}
 
-	if len(t.Dynamics) == 0 {
+	if t.isString() {
 		if len(t.Statics) != 1 {
 			panic(fmt.Sprintf("internal error: malformed tree with 0 dynamics and %d statics", len(t.Statics)))
 		}
@@ -250,17 +427,9 @@ func (t *Tree) writeTo(cw *countWriter) {
 			cw.writeDynamic(d)
 		}
 	} else {
-		cw.writeString(`"d":[`)
-		for i, d := range t.Dynamics {
-			cw.writeLeadingComma(i)
-			cw.writeString(`[`)
-			for j, dd := range d.([]any) {
-				cw.writeLeadingComma(j)
-				cw.writeDynamic(dd)
-			}
-			cw.writeString(`]`)
-		}
-		cw.writeString(`]`)
+		cw.writeString(`"d":`)
+		t.writeRangeDynamics(cw)
+		t.writeStream(cw)
 	}
 
 	if !t.ExcludeStatics {
@@ -273,28 +442,103 @@ func (t *Tree) writeTo(cw *countWriter) {
 		cw.writeString(`]`)
 	}
 
+	if len(t.Components) > 0 {
+		cw.writeString(`,"c":{`)
+		for i, cid := range sortedKeys(t.Components) {
+			cw.writeLeadingComma(i)
+			cw.writeString(`"`)
+			cw.writeInt(cid)
+			cw.writeString(`":`)
+			t.Components[cid].writeComponent(cw)
+		}
+		cw.writeString(`}`)
+	}
+
+	t.writeRootMembers(cw, 1)
+	cw.writeString(`}`)
+}
+
+// isString reports whether t is serialized as a plain string.
+// A stream with changes is always an object, even without items, to carry its deletes.
+func (t *Tree) isString() bool {
+	return len(t.Dynamics) == 0 && t.stream == nil
+}
+
+// writeStream writes range tree t's stream payload, if any, as an object member.
+func (t *Tree) writeStream(cw *countWriter) {
+	if t.stream == nil {
+		return
+	}
+	cw.writeString(`,"stream":`)
+	cw.writeBytes(t.stream)
+}
+
+// writeComponent writes component tree t to cw.
+// Unlike other trees, components are always written as objects,
+// because the client requires statics for every component.
+func (t *Tree) writeComponent(cw *countWriter) {
+	if len(t.Dynamics) > 0 {
+		t.writeTo(cw)
+		return
+	}
+	cw.writeString(`{"s":[`)
+	cw.writeJSONString(t.Statics[0])
+	cw.writeString(`]}`)
+}
+
+// writeRangeDynamics writes the dynamics of range tree t as a JSON array of arrays.
+func (t *Tree) writeRangeDynamics(cw *countWriter) {
+	cw.writeString(`[`)
+	for i, d := range t.Dynamics {
+		cw.writeLeadingComma(i)
+		cw.writeString(`[`)
+		for j, dd := range d.([]any) {
+			cw.writeLeadingComma(j)
+			cw.writeDynamic(dd)
+		}
+		cw.writeString(`]`)
+	}
+	cw.writeString(`]`)
+}
+
+// writeRootMembers writes t's title, events and reply, if any, as object members.
+// n is the number of members already written to the enclosing object.
+func (t *Tree) writeRootMembers(cw *countWriter, n int) {
 	if t.Title != "" {
-		cw.writeString(`,"t":`)
+		cw.writeLeadingComma(n)
+		cw.writeString(`"t":`)
 		cw.writeJSONString(t.Title)
+		n++
 	}
 
 	if len(t.Events) > 0 {
-		cw.writeString(`,"e":[`)
+		cw.writeLeadingComma(n)
+		cw.writeString(`"e":[`)
 		for i, e := range t.Events {
 			cw.writeLeadingComma(i)
 			cw.writeBytes(e)
 		}
 		cw.writeString(`]`)
+		n++
 	}
 
-	cw.writeString(`}`)
+	if t.Reply != nil {
+		cw.writeLeadingComma(n)
+		cw.writeString(`"r":`)
+		cw.writeBytes(t.Reply)
+	}
 }
 
 // RenderTo renders the content represented by t to w.
 func (t *Tree) RenderTo(w io.Writer) error {
-	if t.Events != nil || t.Title != "" {
-		return fmt.Errorf("RenderTo does not support events or title")
+	if t.Events != nil || t.Title != "" || t.Reply != nil {
+		return fmt.Errorf("RenderTo does not support events, title or reply")
 	}
+	return t.renderTo(w, t.Components)
+}
+
+// renderTo renders t to w, looking up component IDs in components.
+func (t *Tree) renderTo(w io.Writer, components map[int]*Tree) error {
 	dynamics := t.Dynamics
 	if !t.isRange {
 		dynamics = []any{t.Dynamics}
@@ -314,7 +558,15 @@ func (t *Tree) RenderTo(w io.Writer) error {
 					return err
 				}
 			case *Tree:
-				if err := dyn.RenderTo(w); err != nil {
+				if err := dyn.renderTo(w, components); err != nil {
+					return err
+				}
+			case int:
+				c, ok := components[dyn]
+				if !ok {
+					return fmt.Errorf("no component with ID %d", dyn)
+				}
+				if err := c.renderTo(w, components); err != nil {
 					return err
 				}
 			default:
@@ -268,6 +268,155 @@ func TestEvents(t *testing.T) {
 	}
 }
 
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		a, b dot
+		want string
+	}{
+		{
+			tmpl: `<h1>{{ .X }}</h1>{{ .Y }}`,
+			a:    dot{"X": "foo", "Y": "bar"},
+			b:    dot{"X": "foo", "Y": "baz"},
+			want: `{"1":"baz"}`,
+		},
+		{
+			tmpl: `<h1>{{ .X }}</h1>`,
+			a:    dot{"X": "foo"},
+			b:    dot{"X": "foo"},
+			want: `{}`,
+		},
+		{
+			tmpl: `{{ if .X }}<b>{{ .X }}</b>{{ end }}`,
+			a:    dot{"X": "foo"},
+			b:    dot{"X": "bar"},
+			want: `{"0":{"0":"bar"}}`,
+		},
+		{
+			tmpl: `{{ if .X }}<b>{{ .X }}</b>{{ else }}<i>{{ .Y }}</i>{{ end }}`,
+			a:    dot{"X": "foo", "Y": "bar"},
+			b:    dot{"X": "", "Y": "bar"},
+			want: `{"0":{"0":"bar","s":["<i>","</i>"]}}`,
+		},
+		{
+			tmpl: `{{ range .X }}<li>{{ . }}</li>{{ end }}`,
+			a:    dot{"X": []string{"a", "b"}},
+			b:    dot{"X": []string{"a", "b", "c"}},
+			want: `{"0":{"d":[["a"],["b"],["c"]]}}`,
+		},
+		{
+			tmpl: `{{ range .X }}<li>{{ . }}</li>{{ end }}`,
+			a:    dot{"X": []string{}},
+			b:    dot{"X": []string{"a"}},
+			want: `{"0":{"d":[["a"]],"s":["<li>","</li>"]}}`,
+		},
+	}
+	for _, test := range tests {
+		x := htmltmpl.Must(htmltmpl.New("test_tmpl").Parse(test.tmpl))
+		a, err := x.ExecuteTree(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := x.ExecuteTree(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := tmpl.Diff(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("Diff(%q):\ngot  %s\nwant %s", test.tmpl, got, test.want)
+		}
+	}
+}
+
+func TestDiffNil(t *testing.T) {
+	root := tmpl.NewTree()
+	root.AppendDynamic("abc")
+	root.AppendStatic("def")
+	root.Title = "title"
+	want, err := root.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Diff(nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %s want %s", got, want)
+	}
+}
+
+func TestDiffRootMembers(t *testing.T) {
+	a := tmpl.NewTree()
+	a.AppendDynamic("abc")
+	b := tmpl.NewTree()
+	b.AppendDynamic("abc")
+	b.Title = "title"
+	b.Events = [][]byte{[]byte(`["ping",{}]`)}
+	b.Reply = []byte(`{"ok":true}`)
+	got, err := tmpl.Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"t":"title","e":[["ping",{}]],"r":{"ok":true}}`
+	if string(got) != want {
+		t.Fatalf("got %s want %s", got, want)
+	}
+}
+
+func TestComponents(t *testing.T) {
+	newTree := func(s string) *tmpl.Tree {
+		root := tmpl.NewTree()
+		root.AppendStatic("<div>")
+		root.Dynamics = append(root.Dynamics, 1)
+		root.Statics = append(root.Statics, "</div>")
+		c := tmpl.NewTree()
+		c.AppendStatic("<p>")
+		c.AppendDynamic(s)
+		c.AppendStatic("</p>")
+		root.Components = map[int]*tmpl.Tree{1: c}
+		return root
+	}
+	a, b := newTree("foo"), newTree("bar")
+	got, err := a.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"0":1,"s":["<div>","</div>"],"c":{"1":{"0":"foo","s":["<p>","</p>"]}}}`
+	if string(got) != want {
+		t.Fatalf("got %s want %s", got, want)
+	}
+
+	buf := new(strings.Builder)
+	err = a.RenderTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "<div><p>foo</p></div>" {
+		t.Fatalf("rendered %q", buf.String())
+	}
+
+	got, err = tmpl.Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantDiff = `{"c":{"1":{"0":"bar"}}}`
+	if string(got) != wantDiff {
+		t.Fatalf("diff got %s want %s", got, wantDiff)
+	}
+
+	got, err = tmpl.Diff(b, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{}` {
+		t.Fatalf("diff got %s want {}", got)
+	}
+}
+
 func FuzzTreeSerialization(f *testing.F) {
 	f.Fuzz(func(t *testing.T, seed int64, s string, n byte) {
 		if !utf8.ValidString(s) {
@@ -345,3 +494,102 @@ func BenchmarkDeep(b *testing.B) {
 		}
 	}
 }
+
+// testStream is a tmpl.Streamer for testing.
+type testStream struct {
+	items   []string
+	payload string
+}
+
+func (s *testStream) TreeStream() (any, []byte) {
+	if s.payload == "" {
+		return s.items, nil
+	}
+	return s.items, []byte(s.payload)
+}
+
+func TestDiffStream(t *testing.T) {
+	x := htmltmpl.Must(htmltmpl.New("stream").Parse(`<ul>{{ range . }}<li id="{{ . }}">{{ . }}</li>{{ end }}</ul>`))
+	renders := []struct {
+		stream *testStream
+		want   string
+	}{
+		{
+			&testStream{[]string{"a"}, `[{"a":-1},[]]`},
+			`{"0":{"d":[["a","a"]],"stream":[{"a":-1},[]],"s":["<li id=\"","\">","</li>"]},"s":["<ul>","</ul>"]}`,
+		},
+		{
+			// the same item again is an update, so it must be sent
+			&testStream{[]string{"a"}, `[{"a":-1},[]]`},
+			`{"0":{"d":[["a","a"]],"stream":[{"a":-1},[]]}}`,
+		},
+		{
+			// deletes are sent without items
+			&testStream{nil, `[{},["a"]]`},
+			`{"0":{"d":[],"stream":[{},["a"]],"s":[""]}}`,
+		},
+		{
+			// without changes, nothing is sent for the range, and the client keeps its items
+			&testStream{nil, ""},
+			`{"0":""}`,
+		},
+		{
+			&testStream{nil, ""},
+			`{}`,
+		},
+	}
+	var prev *tmpl.Tree
+	for i, r := range renders {
+		tree, err := x.ExecuteTree(r.stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := tmpl.Diff(prev, tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != r.want {
+			t.Errorf("render %d: got %s want %s", i, got, r.want)
+		}
+		prev = tree
+	}
+
+	// HTML rendering ranges over the items
+	var b strings.Builder
+	err := x.Execute(&b, &testStream{[]string{"a", "b"}, `[{"a":-1,"b":-1},[]]`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<ul><li id="a">a</li><li id="b">b</li></ul>`; b.String() != want {
+		t.Errorf("got %s want %s", b.String(), want)
+	}
+}
+
+func TestDiffStaticRoot(t *testing.T) {
+	x := htmltmpl.Must(htmltmpl.New("static").Parse(`<p>static</p>`))
+	a, err := x.ExecuteTree(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x.ExecuteTree(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Title = "title"
+	for _, tc := range []struct {
+		a, b *tmpl.Tree
+		want string
+	}{
+		{nil, a, `{"s":["<p>static</p>"]}`},
+		{a, a, `{}`},
+		{a, b, `{"t":"title"}`},
+	} {
+		got, err := tmpl.Diff(tc.a, tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("got %s want %s", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package live
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// checkOrigin reports whether the websocket request r comes from an allowed origin:
+// the same host as r, or one of c.AllowedOrigins. Requests without an Origin header
+// do not come from browsers, so they are not subject to cross-site attacks and are allowed.
+func (c *Config) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// https://*.example.com matches the subdomains of example.com
+		scheme, domain, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.EqualFold(scheme, u.Scheme) && strings.HasSuffix(strings.ToLower(u.Host), "."+strings.ToLower(domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientKey returns the key r's client is admitted under; see Config.ClientKey.
+func (c *Config) clientKey(r *http.Request) string {
+	if c.ClientKey != nil {
+		return c.ClientKey(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// admissionSweepInterval is how often the state of clients that are gone is dropped.
+const admissionSweepInterval = time.Minute
+
+// admission tracks the websockets of a WebsocketHandler, to enforce Config's
+// MaxConnections, MaxConnectionsPerClient, JoinRate and JoinBurst.
+type admission struct {
+	config *Config
+
+	mu      sync.Mutex
+	conns   int
+	clients map[string]*admittedClient
+	swept   time.Time
+}
+
+// admittedClient is the state of the websockets of a client key.
+type admittedClient struct {
+	conns int
+	joins *rate.Limiter // nil if joins are not limited
+}
+
+func newAdmission(c *Config) *admission {
+	return &admission{config: c, clients: make(map[string]*admittedClient)}
+}
+
+// admit admits a websocket for the client key, returning the function to call once
+// it is closed, or the HTTP status to reject it with.
+func (a *admission) admit(key string) (release func(), status int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sweep()
+	if limit := a.config.MaxConnections; limit > 0 && a.conns >= limit {
+		return nil, http.StatusServiceUnavailable
+	}
+	cl := a.client(key)
+	if limit := a.config.MaxConnectionsPerClient; limit > 0 && cl.conns >= limit {
+		return nil, http.StatusTooManyRequests
+	}
+	// the websocket's joins take tokens, but a client out of them need not connect
+	if cl.joins != nil && cl.joins.Tokens() < 1 {
+		return nil, http.StatusTooManyRequests
+	}
+	a.conns++
+	cl.conns++
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.conns--
+		cl.conns--
+	}, 0
+}
+
+// allowJoin reports whether the client key may join another View.
+func (a *admission) allowJoin(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cl := a.client(key)
+	return cl.joins == nil || cl.joins.Allow()
+}
+
+// client returns the state of the client key. a.mu must be held.
+func (a *admission) client(key string) *admittedClient {
+	cl, ok := a.clients[key]
+	if !ok {
+		cl = &admittedClient{}
+		if a.config.JoinRate > 0 {
+			cl.joins = rate.NewLimiter(a.config.JoinRate, max(a.config.JoinBurst, 1))
+		}
+		a.clients[key] = cl
+	}
+	return cl
+}
+
+// sweep drops the state of clients that have no websockets and whose join
+// limiter, if any, is full again, so that it is as if they had never connected.
+// a.mu must be held.
+func (a *admission) sweep() {
+	now := time.Now()
+	if now.Sub(a.swept) < admissionSweepInterval {
+		return
+	}
+	a.swept = now
+	for key, cl := range a.clients {
+		if cl.conns == 0 && (cl.joins == nil || cl.joins.TokensAt(now) >= float64(cl.joins.Burst())) {
+			delete(a.clients, key)
+		}
+	}
+}
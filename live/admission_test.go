@@ -0,0 +1,127 @@
+package live
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/time/rate"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://*.example.org"}
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"https://live.test", true}, // same host
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"http://a.example.org", false},
+		{"https://evil.com", false},
+		{"https://app.example.com.evil.com", false},
+		{"null", false},
+	}
+	x := NewWebsocketHandler(Config{AllowedOrigins: allowed})
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "https://live.test/live/websocket", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		rec := httptest.NewRecorder()
+		x.ServeHTTP(rec, r)
+		// allowed requests get as far as failing to upgrade, as they are not websocket requests
+		if forbidden := rec.Code == http.StatusForbidden; forbidden == test.ok {
+			t.Errorf("origin %q: got status %d, want allowed %v", test.origin, rec.Code, test.ok)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "https://live.test/live/websocket", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	rec := httptest.NewRecorder()
+	NewWebsocketHandler(Config{AllowedOrigins: []string{"*"}}).ServeHTTP(rec, r)
+	if rec.Code == http.StatusForbidden {
+		t.Error("origin allowed by * was forbidden")
+	}
+}
+
+// dialStatus dials srv's websocket as client, returning the HTTP status of a rejection, or 0.
+func dialStatus(t *testing.T, srv *httptest.Server, client string) int {
+	t.Helper()
+	conn, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/live/websocket", http.Header{"X-Client": {client}})
+	if err != nil {
+		if res == nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode
+	}
+	t.Cleanup(func() { conn.Close() })
+	return 0
+}
+
+func TestConnectionLimits(t *testing.T) {
+	c := newTestConfig(nil)
+	c.MaxConnections = 3
+	c.MaxConnectionsPerClient = 2
+	c.ClientKey = func(r *http.Request) string { return r.Header.Get("X-Client") }
+	srv := newTestServer(t, c)
+
+	for _, test := range []struct {
+		client string
+		status int
+	}{
+		{"a", 0},
+		{"a", 0},
+		{"a", http.StatusTooManyRequests},
+		{"b", 0},
+		{"c", http.StatusServiceUnavailable},
+	} {
+		if status := dialStatus(t, srv, test.client); status != test.status {
+			t.Fatalf("dialing as %s: got status %d, want %d", test.client, status, test.status)
+		}
+	}
+}
+
+func TestConnectionReleased(t *testing.T) {
+	c := newTestConfig(nil)
+	c.MaxConnections = 1
+	srv := newTestServer(t, c)
+	conn := dial(t, srv)
+	conn.conn.Close()
+	// the server notices the close asynchronously
+	deadline := time.Now().Add(5 * time.Second)
+	for dialStatus(t, srv, "") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("connection not released after closing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJoinRate(t *testing.T) {
+	c := newTestConfig(map[string]func() View{
+		"/plain": func() View { return new(plainView) },
+	})
+	c.JoinRate = rate.Every(time.Hour)
+	c.JoinBurst = 1
+	srv := newTestServer(t, c)
+	page := getPage(t, srv, "/plain")
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join: got %s %v", event, payload)
+	}
+	event, payload := conn.join(page)
+	resp, _ := payload["response"].(map[string]any)
+	if event != "phx_reply" || payload["status"] != "error" || resp["reason"] != joinRateLimited {
+		t.Fatalf("second join: got %s %v, want rate limited", event, payload)
+	}
+	if status := dialStatus(t, srv, ""); status != http.StatusTooManyRequests {
+		t.Fatalf("dialing out of joins: got status %d, want %d", status, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,156 @@
+package live
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/canopyclimate/golive/live/internal/phx"
+)
+
+// AsyncHandler is an interface that can be implemented by a View to receive
+// the results of work started with StartAsync.
+type AsyncHandler interface {
+	HandleAsync(ctx context.Context, name string, result any, err error) error
+}
+
+// asyncDone is the outcome of async work, to be applied by the serve loop.
+type asyncDone struct {
+	task  context.Context // cancelled if the result is no longer wanted
+	name  string
+	apply func(context.Context) error
+}
+
+// StartAsync runs fn in a new goroutine and passes its result to the View's HandleAsync method
+// (see AsyncHandler), after which the View is re-rendered. Calling StartAsync again with
+// the same name cancels the previous work of that name, as does the View terminating:
+// the context passed to fn is cancelled and its result discarded.
+//
+// StartAsync does nothing unless the View is connected, so slow work does not hold up
+// the initial HTTP render; see Connected. It must be called from the View's methods.
+func StartAsync(ctx context.Context, name string, fn func(context.Context) (any, error)) {
+	startAsync(ctx, name, fn, func(ctx context.Context, s *socket, result any, err error) error {
+		ah, ok := s.view.(AsyncHandler)
+		if !ok {
+			return fmt.Errorf("view does not implement AsyncHandler")
+		}
+		return ah.HandleAsync(ctx, name, result, err)
+	})
+}
+
+// CancelAsync cancels the work with the given name started by StartAsync or AssignAsync, if any.
+func CancelAsync(ctx context.Context, name string) {
+	s := socketValue(ctx)
+	if s == nil {
+		return
+	}
+	if cancel, ok := s.asyncs[name]; ok {
+		cancel()
+		delete(s.asyncs, name)
+	}
+}
+
+// AsyncResult holds the result of work started with AssignAsync, for rendering, e.g.:
+//
+//	{{ if .Orders.Loading }}Loading…{{ else if .Orders.Failed }}{{ .Orders.Err }}{{ else }}{{ range .Orders.Result }}…{{ end }}{{ end }}
+type AsyncResult[T any] struct {
+	// Result is the result of the work, once it has succeeded.
+	Result T
+	// Err is the error returned by the work, once it has failed.
+	Err   error
+	state asyncState
+}
+
+type asyncState int
+
+const (
+	asyncIdle asyncState = iota
+	asyncLoading
+	asyncFinished
+)
+
+// Loading reports whether the work is still running.
+func (r AsyncResult[T]) Loading() bool { return r.state == asyncLoading }
+
+// OK reports whether the work has succeeded.
+func (r AsyncResult[T]) OK() bool { return r.state == asyncFinished && r.Err == nil }
+
+// Failed reports whether the work has failed.
+func (r AsyncResult[T]) Failed() bool { return r.state == asyncFinished && r.Err != nil }
+
+// AssignAsync sets *dst to loading and runs fn in a new goroutine, storing its result in *dst
+// once it is done, after which the View is re-rendered. Like StartAsync, the work is cancelled
+// when the View terminates or AssignAsync is called again with the same dst.
+//
+// During the initial HTTP render, *dst is left loading and fn is not run.
+// AssignAsync must be called from the View's methods.
+func AssignAsync[T any](ctx context.Context, dst *AsyncResult[T], fn func(context.Context) (T, error)) {
+	*dst = AsyncResult[T]{state: asyncLoading}
+	name := fmt.Sprintf("golive-assign-async:%p", dst)
+	startAsync(ctx, name, func(ctx context.Context) (any, error) {
+		return fn(ctx)
+	}, func(ctx context.Context, s *socket, result any, err error) error {
+		*dst = AsyncResult[T]{Err: err, state: asyncFinished}
+		if err == nil {
+			dst.Result, _ = result.(T)
+		}
+		return nil
+	})
+}
+
+// startAsync runs fn in a new goroutine and has the serve loop call done with its result.
+func startAsync(ctx context.Context, name string, fn func(context.Context) (any, error), done func(context.Context, *socket, any, error) error) {
+	s := socketValue(ctx)
+	if s == nil || !s.connected {
+		return
+	}
+	view := viewContext(ctx)
+	if view == nil || view.Err() != nil {
+		return
+	}
+	CancelAsync(ctx, name)
+	if s.asyncs == nil {
+		s.asyncs = make(map[string]context.CancelFunc)
+	}
+	task, cancel := context.WithCancel(view)
+	s.asyncs[name] = cancel
+
+	go func() {
+		result, err := runAsync(task, fn)
+		d := asyncDone{
+			task: task,
+			name: name,
+			apply: func(ctx context.Context) error {
+				return done(ctx, s, result, err)
+			},
+		}
+		select {
+		case s.async <- d:
+		case <-task.Done():
+		case <-s.done:
+		}
+	}()
+}
+
+// runAsync calls fn, turning panics into a *PanicError.
+func runAsync(ctx context.Context, fn func(context.Context) (any, error)) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// handleAsync applies the outcome of async work and returns the resulting diff.
+func (s *socket) handleAsync(ctx context.Context, d asyncDone) ([]byte, error) {
+	CancelAsync(ctx, d.name) // release the task's context
+	err := d.apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := s.renderDiff(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rendering error: %v", err)
+	}
+	return phx.NewDiff(nil, s.id, diff).JSON()
+}
@@ -0,0 +1,88 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+type asyncView struct {
+	release   chan string   // releases Orders
+	cancelled chan struct{} // closed when Slow is cancelled
+	Orders    AsyncResult[string]
+	Report    string
+}
+
+func (v *asyncView) Mount(ctx context.Context, p Params) error {
+	AssignAsync(ctx, &v.Orders, func(ctx context.Context) (string, error) {
+		return <-v.release, nil
+	})
+	StartAsync(ctx, "report", func(ctx context.Context) (any, error) {
+		return nil, errors.New("no report")
+	})
+	StartAsync(ctx, "slow", func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		close(v.cancelled)
+		return nil, ctx.Err()
+	})
+	return nil
+}
+
+func (v *asyncView) HandleAsync(ctx context.Context, name string, result any, err error) error {
+	if name != "report" {
+		return errors.New("unexpected async result " + name)
+	}
+	v.Report = err.Error()
+	return nil
+}
+
+func (v *asyncView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("async").Parse(
+		`<p>{{ if .Orders.Loading }}loading{{ else if .Orders.OK }}{{ .Orders.Result }}{{ end }}</p><p>{{ .Report }}</p>`,
+	))
+}
+
+func TestAsync(t *testing.T) {
+	v := &asyncView{release: make(chan string), cancelled: make(chan struct{})}
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/async": func() View { return v },
+	}))
+	page := getPage(t, srv, "/async")
+	if want := "<p>loading</p><p></p>"; !strings.Contains(page.body, want) {
+		t.Fatalf("HTTP render missing %q:\n%s", want, page.body)
+	}
+
+	conn := dial(t, srv)
+	_, payload := conn.join(page)
+	rendered := payload["response"].(map[string]any)["rendered"].(map[string]any)
+	if rendered["0"] == nil {
+		t.Fatalf("join didn't render loading: %v", rendered)
+	}
+
+	// the failing report arrives first, while orders are still loading
+	event, payload := conn.recv()
+	if event != "diff" || payload["1"] != "no report" {
+		t.Fatalf("got %s %v, want diff with report error", event, payload)
+	}
+	v.release <- "3 orders"
+	event, payload = conn.recv()
+	if event != "diff" {
+		t.Fatalf("got %s %v, want diff", event, payload)
+	}
+	if b, _ := json.Marshal(payload); !strings.Contains(string(b), `"3 orders"`) {
+		t.Fatalf("got %s, want orders", b)
+	}
+
+	// disconnecting cancels outstanding work
+	conn.conn.UnderlyingConn().Close()
+	select {
+	case <-v.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow work not cancelled on disconnect")
+	}
+}
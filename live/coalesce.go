@@ -0,0 +1,98 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canopyclimate/golive/live/internal/phx"
+)
+
+// RenderCoalescer is an interface that can be implemented by a View receiving bursts of
+// Infos, e.g. from a chatty pubsub topic, to render once per burst rather than once per Info.
+// All Infos queued for the View are passed to HandleInfo before it is rendered.
+//
+// MaxFrameRate returns the most times per second the View is rendered because of Infos,
+// or 0 for no cap, so that the View is rendered after every burst. Infos arriving sooner
+// are handled straight away, but the View is rendered once the frame is due. Renders
+// caused by the client, e.g. by events, are never delayed, and include the changes of
+// the Infos handled so far.
+type RenderCoalescer interface {
+	MaxFrameRate() float64
+}
+
+// frameTimer schedules the render of a View whose frame rate is limited; see RenderCoalescer.
+type frameTimer struct {
+	last  time.Time   // when the View was last rendered for Infos
+	timer *time.Timer // non-nil while a render is due
+}
+
+// C returns the channel a due render is signalled on, or nil if none is.
+func (f *frameTimer) C() <-chan time.Time {
+	if f.timer == nil {
+		return nil
+	}
+	return f.timer.C
+}
+
+// stop cancels the due render, if any. A tick already sent on the timer's channel
+// is dropped with it, as C no longer returns the channel.
+func (f *frameTimer) stop() {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+}
+
+// handleInfos passes info, then the other Infos queued for the View, to HandleInfo,
+// and returns the resulting diff, or nil if the render is postponed; see renderFrame.
+func (s *socket) handleInfos(ctx context.Context, rc RenderCoalescer, info *Info) ([]byte, error) {
+	ih, ok := s.view.(InfoHandler)
+	if !ok {
+		return nil, fmt.Errorf("view does not implement InfoHandler")
+	}
+	err := ih.HandleInfo(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	// Only drain the Infos queued so far, lest a View sending itself Infos never renders.
+	// Senders may drop queued Infos meanwhile (see DropOldest), so do not wait for them.
+	for n := len(s.info); n > 0; n-- {
+		var m mail
+		select {
+		case m = <-s.info:
+		default:
+			return s.renderFrame(ctx, rc)
+		}
+		if m.view.Err() != nil {
+			// sent to a View that has since terminated
+			continue
+		}
+		err := ih.HandleInfo(ctx, m.info)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.renderFrame(ctx, rc)
+}
+
+// renderFrame returns the diff rendering the Infos the View has handled. If that would
+// exceed its MaxFrameRate, it returns nil instead, and the render is signalled on s.frame.C
+// once it is due.
+func (s *socket) renderFrame(ctx context.Context, rc RenderCoalescer) ([]byte, error) {
+	if fps := rc.MaxFrameRate(); fps > 0 {
+		due := s.frame.last.Add(time.Duration(float64(time.Second) / fps))
+		if wait := time.Until(due); wait > 0 {
+			if s.frame.timer == nil {
+				s.frame.timer = time.NewTimer(wait)
+			}
+			return nil, nil
+		}
+	}
+	s.frame.last = time.Now()
+	diff, err := s.renderDiff(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rendering error: %v", err)
+	}
+	return phx.NewDiff(nil, s.id, diff).JSON()
+}
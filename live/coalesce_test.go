@@ -0,0 +1,124 @@
+package live
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+// burstView handles bursts of Infos sent by its events, counting renders.
+type burstView struct {
+	fps     float64
+	Infos   int
+	renders atomic.Int32
+}
+
+func (v *burstView) MaxFrameRate() float64 { return v.fps }
+
+func (v *burstView) HandleEvent(ctx context.Context, e *Event) error {
+	switch e.Type {
+	case "burst":
+		for i := 0; i < 10; i++ {
+			SendInfo(ctx, &Info{Type: "tick"})
+		}
+	case "stream":
+		Every(ctx, time.Millisecond, &Info{Type: "tick"})
+	}
+	return nil
+}
+
+func (v *burstView) HandleInfo(ctx context.Context, info *Info) error {
+	v.Infos++
+	return nil
+}
+
+func (v *burstView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	v.renders.Add(1)
+	return v, htmltmpl.Must(htmltmpl.New("burst").Parse(`<p>{{ .Infos }}</p>`))
+}
+
+func joinBurstView(t *testing.T, v *burstView) (*testConn, testPage) {
+	t.Helper()
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/burst": func() View { return v },
+	}))
+	page := getPage(t, srv, "/burst")
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join: got %s %v", event, payload)
+	}
+	return conn, page
+}
+
+func TestCoalesceInfoBurst(t *testing.T) {
+	v := new(burstView)
+	conn, page := joinBurstView(t, v)
+	v.renders.Store(0)
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "burst", "value": map[string]any{}})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("event: got %s %v", event, payload)
+	}
+	// the Infos queued while handling the event are rendered at once
+	event, payload := conn.recv()
+	if event != "diff" || payload["0"] != "10" {
+		t.Fatalf("burst: got %s %v, want a diff of all 10 infos", event, payload)
+	}
+	if n := v.renders.Load(); n != 2 {
+		t.Errorf("got %d renders, want 2 (the event and the burst)", n)
+	}
+}
+
+func TestMaxFrameRate(t *testing.T) {
+	v := &burstView{fps: 10}
+	conn, page := joinBurstView(t, v)
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "stream", "value": map[string]any{}})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("event: got %s %v", event, payload)
+	}
+	// Infos arrive every millisecond, but the View renders for them every 100ms
+	diffs := 0
+	for end := time.Now().Add(350 * time.Millisecond); time.Now().Before(end); {
+		conn.conn.SetReadDeadline(end)
+		if _, _, err := conn.conn.ReadMessage(); err != nil {
+			break
+		}
+		diffs++
+	}
+	if diffs < 1 || diffs > 5 {
+		t.Errorf("got %d diffs in 350ms, want at most 10 a second", diffs)
+	}
+}
+
+func TestEventRenderCancelsFrame(t *testing.T) {
+	v := &burstView{fps: 5}
+	conn, page := joinBurstView(t, v)
+	burst := map[string]any{"type": "click", "event": "burst", "value": map[string]any{}}
+	// the first burst renders straight away, the second waits for its frame
+	for _, want := range []string{"10", ""} {
+		conn.send(page.topic, "event", burst)
+		if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+			t.Fatalf("event: got %s %v", event, payload)
+		}
+		if want == "" {
+			break
+		}
+		if event, payload := conn.recv(); event != "diff" || payload["0"] != want {
+			t.Fatalf("burst: got %s %v, want a diff of %s infos", event, payload, want)
+		}
+	}
+	// an event renders the second burst, so there is nothing left for its frame
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "noop", "value": map[string]any{}})
+	event, payload := conn.recv()
+	resp, _ := payload["response"].(map[string]any)
+	diff, _ := resp["diff"].(map[string]any)
+	if event != "phx_reply" || diff["0"] != "20" {
+		t.Fatalf("event: got %s %v, want a diff of 20 infos", event, payload)
+	}
+	conn.conn.SetReadDeadline(time.Now().Add(400 * time.Millisecond))
+	if _, msg, err := conn.conn.ReadMessage(); err == nil {
+		t.Errorf("got %s after the frame, want nothing", msg)
+	}
+}
@@ -0,0 +1,183 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+	"github.com/canopyclimate/golive/internal/tmpl"
+)
+
+// Component is a stateful piece of a View with its own lifecycle.
+// Components are embedded in a View's template with the liveComponent template func
+// and are identified by an ID that is unique within their View.
+// The first time a component with a given ID is rendered, the rendered Component is kept
+// and reused for subsequent renders, so its state survives re-rendering of its parent.
+//
+// A Component may also implement ComponentMounter, Updater and EventHandler.
+// Events from elements with a phx-target of the component's Meta.Myself are
+// routed to the component's HandleEvent method instead of the View's.
+type Component interface {
+	// Render returns the dot and template needed to turn a Component into HTML.
+	// Pass the *Meta through to the template to embed other components.
+	Render(context.Context, *Meta) (any, *htmltmpl.Template)
+}
+
+// ComponentMounter is an interface that can be implemented by a Component to be notified
+// when it is first rendered.
+type ComponentMounter interface {
+	Mount(context.Context) error
+}
+
+// Updater is an interface that can be implemented by a Component to receive
+// the assigns passed to it by its parent template every time it is rendered.
+// Update is called after Mount and before Render.
+type Updater interface {
+	Update(ctx context.Context, assigns any) error
+}
+
+// components tracks the stateful Components of a single View.
+type components struct {
+	byID    map[string]*component
+	byCID   map[int]*component
+	lastCID int
+}
+
+type component struct {
+	cid int
+	id  string
+	c   Component
+}
+
+func newComponents() *components {
+	return &components{
+		byID:  make(map[string]*component),
+		byCID: make(map[int]*component),
+	}
+}
+
+// get returns the component with the given id, creating and mounting c if there is none yet
+// or if the existing component is of a different type.
+func (cs *components) get(ctx context.Context, id string, c Component) (*component, error) {
+	lc, ok := cs.byID[id]
+	if ok && reflect.TypeOf(lc.c) == reflect.TypeOf(c) {
+		return lc, nil
+	}
+	if ok {
+		cs.delete(lc.cid)
+	}
+	cs.lastCID++
+	lc = &component{cid: cs.lastCID, id: id, c: c}
+	if m, ok := c.(ComponentMounter); ok {
+		err := m.Mount(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cs.byID[id] = lc
+	cs.byCID[lc.cid] = lc
+	return lc, nil
+}
+
+// delete removes the component with the given cid, if any.
+func (cs *components) delete(cid int) {
+	lc, ok := cs.byCID[cid]
+	if !ok {
+		return
+	}
+	delete(cs.byCID, cid)
+	delete(cs.byID, lc.id)
+}
+
+// componentRender tracks the components rendered during a single render of a View.
+type componentRender struct {
+	ctx        context.Context
+	components *components
+	trees      map[int]*tmpl.Tree // rendered component trees; nil when rendering HTML
+}
+
+const componentMarkerPrefix = "\x00golive-component:"
+
+// componentMarker is the placeholder that stands in for the component with cid in a rendered tree
+// until resolveComponents replaces it with the component ID.
+func componentMarker(cid int) string {
+	return componentMarkerPrefix + strconv.Itoa(cid) + "\x00"
+}
+
+// resolveComponents replaces component markers in t's dynamics with component IDs.
+func resolveComponents(t *tmpl.Tree) {
+	resolveDynamics(t.Dynamics)
+}
+
+func resolveDynamics(dyns []any) {
+	for i, d := range dyns {
+		switch d := d.(type) {
+		case string:
+			cid, ok := strings.CutPrefix(d, componentMarkerPrefix)
+			if !ok {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(cid, "\x00"))
+			if err != nil {
+				continue
+			}
+			dyns[i] = n
+		case *tmpl.Tree:
+			resolveDynamics(d.Dynamics)
+		case []any:
+			resolveDynamics(d)
+		}
+	}
+}
+
+// ComponentTag renders the Component c with the given id, passing it assigns (if any) via Update.
+// meta must be the *Meta passed to the Render method of the View or Component whose template is
+// calling ComponentTag, and ComponentTag must be the only thing in its template action, e.g.:
+//
+//	{{ liveComponent .Meta "user-form" .UserForm .User }}
+func ComponentTag(meta *Meta, id string, c Component, assigns ...any) (htmltmpl.HTML, error) {
+	if meta == nil || meta.components == nil {
+		return "", fmt.Errorf("liveComponent %q: requires the *live.Meta passed to Render", id)
+	}
+	if len(assigns) > 1 {
+		return "", fmt.Errorf("liveComponent %q: at most one assigns argument allowed, got %d", id, len(assigns))
+	}
+	cr := meta.components
+	lc, err := cr.components.get(cr.ctx, id, c)
+	if err != nil {
+		return "", fmt.Errorf("liveComponent %q: mount: %w", id, err)
+	}
+	if u, ok := lc.c.(Updater); ok {
+		var a any
+		if len(assigns) > 0 {
+			a = assigns[0]
+		}
+		err := u.Update(cr.ctx, a)
+		if err != nil {
+			return "", fmt.Errorf("liveComponent %q: update: %w", id, err)
+		}
+	}
+
+	cmeta := *meta
+	cmeta.Myself = lc.cid
+	dot, t := lc.c.Render(cr.ctx, &cmeta)
+
+	if cr.trees == nil {
+		var buf strings.Builder
+		err := t.Execute(&buf, dot)
+		if err != nil {
+			return "", err
+		}
+		return htmltmpl.HTML(buf.String()), nil
+	}
+	tree, err := t.ExecuteTree(dot)
+	if err != nil {
+		return "", err
+	}
+	resolveComponents(tree)
+	cr.trees[lc.cid] = tree
+	return htmltmpl.HTML(componentMarker(lc.cid)), nil
+}
@@ -0,0 +1,91 @@
+package live
+
+import (
+	"context"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+type testCounter struct {
+	Count   int
+	Label   string
+	mounted int
+}
+
+func (c *testCounter) Mount(ctx context.Context) error {
+	c.mounted++
+	return nil
+}
+
+func (c *testCounter) Update(ctx context.Context, assigns any) error {
+	c.Label, _ = assigns.(string)
+	return nil
+}
+
+func (c *testCounter) HandleEvent(ctx context.Context, e *Event) error {
+	c.Count++
+	return nil
+}
+
+func (c *testCounter) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	dot := map[string]any{"C": c, "Meta": meta}
+	return dot, htmltmpl.Must(htmltmpl.New("counter").Parse(
+		`<button phx-click="inc" phx-target="{{ .Meta.Myself }}">{{ .C.Label }} {{ .C.Count }}</button>`,
+	))
+}
+
+type testParent struct {
+	Label string
+}
+
+func (p *testParent) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	dot := map[string]any{"Meta": meta, "Label": p.Label}
+	funcs := Funcs()
+	funcs["newCounter"] = func() *testCounter { return new(testCounter) }
+	return dot, htmltmpl.Must(htmltmpl.New("parent").Funcs(funcs).Parse(
+		`<div>{{ liveComponent .Meta "counter" newCounter .Label }}</div>`,
+	))
+}
+
+func TestComponentRender(t *testing.T) {
+	s := &socket{
+		view:          &testParent{Label: "clicks"},
+		uploadConfigs: make(map[string]*UploadConfig),
+		components:    newComponents(),
+	}
+	ctx := withSocket(context.Background(), s)
+	got, err := s.renderDiff(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"0":1,"s":["<div>","</div>"],"c":{"1":{"0":"1","1":"clicks","2":"0","s":["<button phx-click=\"inc\" phx-target=\"","\">"," ","</button>"]}}}`
+	if string(got) != want {
+		t.Fatalf("got\n\t%s\nwant\n\t%s", got, want)
+	}
+
+	// Events targeted at the component go to the component, and its state survives re-rendering.
+	eh, err := s.eventHandler(map[string]any{"cid": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = eh.HandleEvent(ctx, &Event{Type: "inc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.renderDiff(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantDiff = `{"c":{"1":{"2":"1"}}}`
+	if string(got) != wantDiff {
+		t.Fatalf("got\n\t%s\nwant\n\t%s", got, wantDiff)
+	}
+	if c := s.components.byCID[1].c.(*testCounter); c.mounted != 1 {
+		t.Fatalf("component mounted %d times, want 1", c.mounted)
+	}
+
+	if _, err := s.eventHandler(map[string]any{"cid": float64(2)}); err == nil {
+		t.Fatal("expected error for unknown cid")
+	}
+}
@@ -0,0 +1,79 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ConnInfo describes the client a View is rendered for.
+// The same information is available during the initial HTTP render
+// and once the View has joined over a websocket.
+type ConnInfo struct {
+	// PeerAddr is the network address of the client, as in http.Request.RemoteAddr.
+	PeerAddr string
+	// UserAgent is the client's User-Agent header.
+	UserAgent string
+	// Header holds the headers of the HTTP request for the initial render,
+	// or of the request that opened the websocket.
+	Header http.Header
+	// Cookies holds the cookies sent with that request.
+	Cookies []*http.Cookie
+	// Params holds the custom params the client's LiveSocket was configured with
+	// (i.e. new LiveSocket(url, Socket, {params: {...}})), excluding those
+	// starting with an underscore, which are reserved for LiveView itself.
+	// Params are only sent when joining over a websocket, so they are nil
+	// during the initial HTTP render.
+	Params map[string]any
+}
+
+// newConnInfo returns the ConnInfo for a client connected via r with the given join params.
+func newConnInfo(r *http.Request, params map[string]any) *ConnInfo {
+	ci := &ConnInfo{
+		PeerAddr:  r.RemoteAddr,
+		UserAgent: r.UserAgent(),
+		Header:    r.Header.Clone(),
+		Cookies:   r.Cookies(),
+	}
+	for k, v := range params {
+		if strings.HasPrefix(k, "_") {
+			continue
+		}
+		if ci.Params == nil {
+			ci.Params = make(map[string]any)
+		}
+		ci.Params[k] = v
+	}
+	return ci
+}
+
+// Cookie returns the named cookie, or http.ErrNoCookie if not found.
+func (ci *ConnInfo) Cookie(name string) (*http.Cookie, error) {
+	for _, c := range ci.Cookies {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, http.ErrNoCookie
+}
+
+// DecodeParams decodes the client's connect params into v,
+// which should be a pointer to a struct or map, as with json.Unmarshal.
+func (ci *ConnInfo) DecodeParams(v any) error {
+	b, err := json.Marshal(ci.Params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ConnectInfo returns information about the client the View associated with ctx is rendered for.
+// It returns nil if ctx is not a View's context.
+func ConnectInfo(ctx context.Context) *ConnInfo {
+	s := socketValue(ctx)
+	if s == nil {
+		return nil
+	}
+	return s.connInfo
+}
@@ -0,0 +1,118 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+type connectView struct {
+	Info string
+}
+
+func (v *connectView) Mount(ctx context.Context, p Params) error {
+	ci := ConnectInfo(ctx)
+	var params struct {
+		TZ     string `json:"tz"`
+		Offset int    `json:"offset"`
+	}
+	err := ci.DecodeParams(&params)
+	if err != nil {
+		return err
+	}
+	c, err := ci.Cookie("user")
+	if err != nil {
+		return err
+	}
+	v.Info = fmt.Sprintf("%s|%s|%s|%s|%d|%t", c.Value, ci.UserAgent, ci.Header.Get("X-Test"), params.TZ, params.Offset, ci.PeerAddr != "")
+	return nil
+}
+
+func (v *connectView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("connect").Parse(`<p>{{ .Info }}</p>`))
+}
+
+func TestConnectInfo(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/connect": func() View { return new(connectView) },
+	}))
+	header := http.Header{
+		"Cookie":     {"user=gopher"},
+		"User-Agent": {"test-agent"},
+		"X-Test":     {"x"},
+	}
+
+	req, err := http.NewRequest("GET", srv.URL+"/connect", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header
+	page := getPageRequest(t, srv, req)
+	if want := "<p>gopher|test-agent|x||0|true</p>"; !strings.Contains(page.body, want) {
+		t.Fatalf("HTTP render missing %q:\n%s", want, page.body)
+	}
+
+	conn := dialHeader(t, srv, header)
+	page.params = map[string]any{"tz": "UTC", "offset": 2}
+	event, payload := conn.join(page)
+	if event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join failed: %s %v", event, payload)
+	}
+	rendered := payload["response"].(map[string]any)["rendered"].(map[string]any)
+	if want := "gopher|test-agent|x|UTC|2|true"; rendered["0"] != want {
+		t.Fatalf("got %v, want %q", rendered["0"], want)
+	}
+}
+
+type connectedView struct {
+	Mounted string
+}
+
+func (v *connectedView) Mount(ctx context.Context, p Params) error {
+	v.Mounted = fmt.Sprintf("%t %t", p.Connected, Connected(ctx))
+	if !Connected(ctx) {
+		// would block forever if not a no-op
+		SendInfo(ctx, &Info{Type: "tick"})
+	}
+	PageTitle(ctx, "title-"+v.Mounted)
+	return nil
+}
+
+func (v *connectedView) HandleInfo(ctx context.Context, info *Info) error {
+	return nil
+}
+
+func (v *connectedView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("connected").Parse(fmt.Sprintf(`<p>{{ .Mounted }} %t</p>`, meta.Connected)))
+}
+
+func TestConnected(t *testing.T) {
+	if Connected(context.Background()) {
+		t.Fatal("background context is connected")
+	}
+	c := newTestConfig(map[string]func() View{
+		"/connected": func() View { return new(connectedView) },
+	})
+	srv := newTestServer(t, c)
+	page := getPage(t, srv, "/connected")
+	if want := "<p>false false false</p>"; !strings.Contains(page.body, want) {
+		t.Fatalf("HTTP render missing %q:\n%s", want, page.body)
+	}
+	if want := "title-false false"; !strings.Contains(page.body, want) {
+		t.Fatalf("HTTP render missing title %q:\n%s", want, page.body)
+	}
+
+	conn := dial(t, srv)
+	event, payload := conn.join(page)
+	if event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join failed: %s %v", event, payload)
+	}
+	rendered := payload["response"].(map[string]any)["rendered"].(map[string]any)
+	if want := "true true"; rendered["0"] != want {
+		t.Fatalf("got %v, want %q", rendered["0"], want)
+	}
+}
@@ -0,0 +1,101 @@
+package live
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Errors a View can return from its methods, usually Mount or HandleParams, to fail with
+// a particular HTTP status. Wrap them to add detail, e.g. fmt.Errorf("%w: no order %s", live.ErrNotFound, id).
+// Other errors are internal errors, with status 500.
+//
+// During the initial HTTP render, the status is that of the response, whose body is rendered
+// by Config.RenderError. Once a View is connected, failing with ErrNotFound or ErrForbidden,
+// including while joining, has the client load the View's URL, so the error page is rendered.
+var (
+	// ErrNotFound reports that what the View was asked to show does not exist (404).
+	ErrNotFound = errors.New("live: not found")
+	// ErrForbidden reports that the client may not see the View (403).
+	ErrForbidden = errors.New("live: forbidden")
+)
+
+// HTTPStatus returns the HTTP status for err: http.StatusNotFound for ErrNotFound,
+// http.StatusForbidden for ErrForbidden and http.StatusInternalServerError otherwise.
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// PanicError is the error a panic is recovered as, when it happens in a View's methods or
+// while handling a client's message. It is reported through Config.OnViewError. Once a View
+// is connected, the client then receives a "phx_error" and rejoins the View; during the
+// initial HTTP render, the response is an internal error page.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the goroutine that panicked, as of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("live: panic: %v", e.Value)
+}
+
+// protect calls fn, recovering a panic as a *PanicError.
+func protect(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+	return fn()
+}
+
+// newPanicError returns the *PanicError for the recovered value r,
+// which must be called from the deferred function that recovered it.
+// http.ErrAbortHandler is panicked again, to abort the request as intended.
+func newPanicError(r any) *PanicError {
+	if r == http.ErrAbortHandler {
+		panic(r)
+	}
+	return &PanicError{Value: r, Stack: debug.Stack()}
+}
+
+// ErrorDot is the data passed to Config.RenderError.
+type ErrorDot struct {
+	// Status is the HTTP status of the response; see HTTPStatus.
+	Status int
+	// StatusText is the text for Status, e.g. "Not Found".
+	StatusText string
+	// Err is the error, which may contain internal details that should not be shown to users.
+	Err error
+}
+
+// renderError responds to r with the error page for err; see Config.RenderError.
+func (c *Config) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	status := HTTPStatus(err)
+	if c.RenderError == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	dot, t := c.RenderError(w, r, &ErrorDot{Status: status, StatusText: http.StatusText(status), Err: err})
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, dot); err != nil {
+		log.Printf("rendering error page: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
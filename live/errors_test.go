@@ -0,0 +1,115 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+// failView fails with err in Mount, or only once connected if connected is set.
+type failView struct {
+	err       error
+	connected bool
+}
+
+func (v *failView) Mount(ctx context.Context, p Params) error {
+	if v.connected && !p.Connected {
+		return nil
+	}
+	return v.err
+}
+
+func (v *failView) HandleEvent(ctx context.Context, e *Event) error {
+	return fmt.Errorf("%w: event %s", ErrForbidden, e.Type)
+}
+
+func (v *failView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("fail").Parse(`<p>ok</p>`))
+}
+
+func TestErrorPages(t *testing.T) {
+	secret := errors.New("secret detail")
+	routes := map[string]func() View{
+		"/missing":   func() View { return &failView{err: fmt.Errorf("%w: %w", ErrNotFound, secret)} },
+		"/forbidden": func() View { return &failView{err: fmt.Errorf("%w: %w", ErrForbidden, secret)} },
+		"/broken":    func() View { return &failView{err: secret} },
+	}
+	errorPage := htmltmpl.Must(htmltmpl.New("error").Parse(`<h1>{{ .Status }} {{ .StatusText }}</h1>`))
+	custom := newTestConfig(routes)
+	custom.RenderError = func(w http.ResponseWriter, r *http.Request, ed *ErrorDot) (any, *htmltmpl.Template) {
+		if !errors.Is(ed.Err, secret) {
+			t.Errorf("RenderError got error %v", ed.Err)
+		}
+		return ed, errorPage
+	}
+
+	tests := []struct {
+		config *Config
+		path   string
+		status int
+		body   string
+	}{
+		{newTestConfig(routes), "/missing", 404, "Not Found\n"},
+		{newTestConfig(routes), "/forbidden", 403, "Forbidden\n"},
+		{newTestConfig(routes), "/broken", 500, "Internal Server Error\n"},
+		{custom, "/missing", 404, "<h1>404 Not Found</h1>"},
+		{custom, "/broken", 500, "<h1>500 Internal Server Error</h1>"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		test.config.Middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("GET", test.path, nil))
+		body, _ := io.ReadAll(rec.Body)
+		if rec.Code != test.status || string(body) != test.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", test.path, rec.Code, body, test.status, test.body)
+		}
+		if strings.Contains(string(body), secret.Error()) {
+			t.Errorf("GET %s: error details leaked: %q", test.path, body)
+		}
+	}
+}
+
+func TestSocketErrorsRedirect(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/join":   func() View { return &failView{err: ErrNotFound, connected: true} },
+		"/broken": func() View { return &failView{err: errors.New("flaky"), connected: true} },
+		"/ok":     func() View { return new(failView) },
+	}))
+
+	// not found errors joining load the page instead of rejoining
+	page := getPage(t, srv, "/join")
+	conn := dial(t, srv)
+	event, payload := conn.join(page)
+	resp, _ := payload["response"].(map[string]any)
+	redirect, _ := resp["redirect"].(map[string]any)
+	if event != "phx_reply" || payload["status"] != "error" || redirect["to"] != page.url {
+		t.Fatalf("join: got %s %v, want redirect to %s", event, payload, page.url)
+	}
+	// and the View is gone, so a client ignoring the redirect cannot use it
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "delete", "value": map[string]any{}})
+	if event, payload := conn.recv(); event != "phx_error" {
+		t.Fatalf("event after failed join: got %s %v, want phx_error", event, payload)
+	}
+
+	// but internal errors have the client rejoin, rather than reload the page over and over
+	page = getPage(t, srv, "/broken")
+	conn = dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_error" {
+		t.Fatalf("join with internal error: got %s %v, want phx_error", event, payload)
+	}
+
+	// as do not found and forbidden errors once joined
+	page = getPage(t, srv, "/ok")
+	conn = dial(t, srv)
+	conn.join(page)
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "delete", "value": map[string]any{}})
+	if event, payload := conn.recv(); event != "redirect" || payload["to"] != page.url {
+		t.Fatalf("event: got %s %v, want redirect to %s", event, payload, page.url)
+	}
+}
@@ -0,0 +1,136 @@
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/canopyclimate/golive/changeset"
+	"github.com/canopyclimate/golive/live/internal/phx"
+)
+
+// KeyEvent describes the key pressed for a keyup or keydown event.
+//
+// The LiveView client only sends the key itself by default. The modifiers are
+// sent if the client's LiveSocket is configured to include them, e.g.:
+//
+//	new LiveSocket("/live", Socket, {metadata: {keydown: (e) => ({altKey: e.altKey, ctrlKey: e.ctrlKey, metaKey: e.metaKey, shiftKey: e.shiftKey, repeat: e.repeat})}})
+type KeyEvent struct {
+	Key      string `json:"key"`
+	AltKey   bool   `json:"altKey"`
+	CtrlKey  bool   `json:"ctrlKey"`
+	MetaKey  bool   `json:"metaKey"`
+	ShiftKey bool   `json:"shiftKey"`
+	Repeat   bool   `json:"repeat"`
+}
+
+// Decode decodes the event's value into v, which should be a pointer to a struct or map.
+// Form events, and events not sent by the client, are decoded from Data with the
+// Config's FormDecoder. Other events are decoded from Raw as with json.Unmarshal,
+// preserving numbers, booleans, arrays and objects sent by phx-value-* attributes or hooks.
+func (e *Event) Decode(v any) error {
+	if e.Kind == "form" || e.Raw == nil {
+		d := e.decoder
+		if d == nil {
+			d = defaultFormDecoder()
+		}
+		return d.Decode(v, e.Data)
+	}
+	return json.Unmarshal(e.Raw, v)
+}
+
+// defaultFormDecoder is the Decoder used when Config.FormDecoder is nil.
+var defaultFormDecoder = sync.OnceValue(func() changeset.Decoder {
+	return changeset.NewGoPlaygroundChangesetConfig()
+})
+
+// formDecoder returns the Decoder for form events.
+func (c *Config) formDecoder() changeset.Decoder {
+	if c.FormDecoder != nil {
+		return c.FormDecoder
+	}
+	return defaultFormDecoder()
+}
+
+// newEvent returns the Event for the "event" msg, whose value is not a form.
+func (s *socket) newEvent(msg *phx.Msg) (*Event, error) {
+	var p struct {
+		Type  string          `json:"type"`
+		Event string          `json:"event"`
+		Value json.RawMessage `json:"value"`
+	}
+	err := json.Unmarshal(msg.RawPayload, &p)
+	if err != nil {
+		return nil, err
+	}
+	e := &Event{Type: p.Event, Kind: p.Type, Data: url.Values{}, decoder: s.config.formDecoder()}
+	if len(p.Value) == 0 || string(p.Value) == "null" {
+		e.Raw = json.RawMessage("{}")
+		return e, nil
+	}
+	e.Raw = p.Value
+	// hooks may push any JSON value, but only objects have fields for Data
+	if bytes.TrimSpace(p.Value)[0] != '{' {
+		return e, nil
+	}
+	var vals map[string]json.RawMessage
+	err = json.Unmarshal(p.Value, &vals)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s event value: %w", p.Type, err)
+	}
+	for k, v := range vals {
+		// strings are added as is, other values as JSON
+		var str string
+		if json.Unmarshal(v, &str) == nil {
+			e.Data.Add(k, str)
+		} else {
+			e.Data.Add(k, string(v))
+		}
+	}
+	if p.Type == "keyup" || p.Type == "keydown" {
+		e.Key = new(KeyEvent)
+		err = json.Unmarshal(p.Value, e.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s event value: %w", p.Type, err)
+		}
+	}
+	return e, nil
+}
+
+// newFormEvent returns the Event for the "event" msg, whose value is a form,
+// adding any files it selects to their UploadConfig.
+func (s *socket) newFormEvent(msg *phx.Msg) (*Event, error) {
+	name, ok := msg.Payload["event"].(string)
+	if !ok {
+		return nil, fmt.Errorf("event name not found in payload")
+	}
+	value, _ := msg.Payload["value"].(string)
+	vals, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, err
+	}
+	e := &Event{
+		Type:    name,
+		Kind:    "form",
+		Data:    vals,
+		Target:  vals.Get("_target"),
+		decoder: s.config.formDecoder(),
+	}
+	// handle uploads before calling HandleEvent
+	if uploads, ok := msg.Payload["uploads"].(map[string]any); ok && len(uploads) != 0 {
+		// get the upload config for _target from the uploadConfigs map
+		uc := s.uploadConfigs[e.Target]
+		// found the upload config & uploads reference the upload config
+		if uc != nil && uc.Ref != "" && uploads[uc.Ref] != nil {
+			entries, ok := uploads[uc.Ref].([]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid uploads for ref %s: %v", uc.Ref, uploads[uc.Ref])
+			}
+			uc.AddEntries(entries)
+			e.Uploads = map[string][]UploadEntry{uc.Name: uc.Entries}
+		}
+	}
+	return e, nil
+}
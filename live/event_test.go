@@ -0,0 +1,217 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+type eventView struct {
+	Events []*Event
+}
+
+func (v *eventView) HandleEvent(ctx context.Context, e *Event) error {
+	v.Events = append(v.Events, e)
+	return nil
+}
+
+func (v *eventView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("event").Parse(`<p>{{ len .Events }}</p>`))
+}
+
+type point struct {
+	X    int      `json:"x" form:"x"`
+	Tags []string `json:"tags" form:"tags"`
+	On   bool     `json:"on" form:"on"`
+	Pos  struct {
+		Lat float64 `json:"lat"`
+	} `json:"pos"`
+}
+
+func TestEventDecode(t *testing.T) {
+	v := new(eventView)
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/event": func() View { return v },
+	}))
+	page := getPage(t, srv, "/event")
+	conn := dial(t, srv)
+	conn.join(page)
+	send := func(payload map[string]any) *Event {
+		t.Helper()
+		conn.send(page.topic, "event", payload)
+		if event, payload := conn.recv(); event != "phx_reply" {
+			t.Fatalf("got %s %v, want phx_reply", event, payload)
+		}
+		return v.Events[len(v.Events)-1]
+	}
+
+	e := send(map[string]any{"type": "hook", "event": "move", "value": map[string]any{
+		"x": 12345678901, "tags": []string{"a", "b"}, "on": true, "pos": map[string]any{"lat": 51.5},
+	}})
+	var p point
+	if err := e.Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.X != 12345678901 || !reflect.DeepEqual(p.Tags, []string{"a", "b"}) || !p.On || p.Pos.Lat != 51.5 {
+		t.Errorf("hook: decoded %+v", p)
+	}
+	if e.Kind != "hook" || e.Data.Get("x") != "12345678901" || e.Data.Get("tags") != `["a","b"]` || e.Data.Get("on") != "true" {
+		t.Errorf("hook: got kind %q, data %v", e.Kind, e.Data)
+	}
+
+	e = send(map[string]any{"type": "hook", "event": "select", "value": []int{1, 2}})
+	var ids []int
+	if err := e.Decode(&ids); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2}) || len(e.Data) != 0 {
+		t.Errorf("hook array: decoded %v, data %v", ids, e.Data)
+	}
+
+	e = send(map[string]any{"type": "keydown", "event": "key", "value": map[string]any{"key": "k", "ctrlKey": true, "value": ""}})
+	if want := (KeyEvent{Key: "k", CtrlKey: true}); e.Key == nil || *e.Key != want {
+		t.Errorf("keydown: got key %+v, want %+v", e.Key, want)
+	}
+
+	e = send(map[string]any{"type": "click", "event": "clicked", "value": map[string]any{}})
+	if e.Key != nil || string(e.Raw) != "{}" {
+		t.Errorf("click: got key %+v, raw %s", e.Key, e.Raw)
+	}
+
+	e = send(map[string]any{"type": "form", "event": "save", "value": "x=7&tags=a&tags=b&on=true&_target=x"})
+	p = point{}
+	if err := e.Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.X != 7 || !reflect.DeepEqual(p.Tags, []string{"a", "b"}) || !p.On {
+		t.Errorf("form: decoded %+v", p)
+	}
+	if e.Kind != "form" || e.Target != "x" || e.Raw != nil {
+		t.Errorf("form: got kind %q, target %q, raw %s", e.Kind, e.Target, e.Raw)
+	}
+}
+
+type incrementEvent struct {
+	By int `json:"by"`
+}
+
+type routedView struct {
+	Count int
+	Key   string
+}
+
+func (v *routedView) HandleEvent(ctx context.Context, e *Event) error {
+	return RouteEvents(v).HandleEvent(ctx, e)
+}
+
+func (v *routedView) HandleIncrement(ctx context.Context, e incrementEvent) error {
+	v.Count += e.By
+	return nil
+}
+
+func (v *routedView) HandleDecrement(ctx context.Context, e *incrementEvent) error {
+	v.Count -= e.By
+	return nil
+}
+
+func (v *routedView) HandleKeyUpdate(ctx context.Context, e *Event) error {
+	v.Key = e.Key.Key
+	return nil
+}
+
+func (v *routedView) HandleParams(ctx context.Context, u *url.URL) error { return nil }
+
+func (v *routedView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("routed").Parse(`<p>{{ .Count }} {{ .Key }}</p>`))
+}
+
+func TestRouteEvents(t *testing.T) {
+	v := new(routedView)
+	if got, want := EventNames(v), []string{"decrement", "increment", "key_update"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/routed": func() View { return v },
+	}))
+	page := getPage(t, srv, "/routed")
+	conn := dial(t, srv)
+	conn.join(page)
+	for _, payload := range []map[string]any{
+		{"type": "click", "event": "increment", "value": map[string]any{"by": 5}},
+		{"type": "hook", "event": "decrement", "value": map[string]any{"by": 2}},
+		{"type": "keydown", "event": "key_update", "value": map[string]any{"key": "Enter"}},
+	} {
+		conn.send(page.topic, "event", payload)
+		if event, payload := conn.recv(); event != "phx_reply" {
+			t.Fatalf("got %s %v, want phx_reply", event, payload)
+		}
+	}
+	if v.Count != 3 || v.Key != "Enter" {
+		t.Errorf("got count %d, key %q, want 3, Enter", v.Count, v.Key)
+	}
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "explode", "value": map[string]any{}})
+	if event, payload := conn.recv(); event != "phx_error" {
+		t.Fatalf("unknown event: got %s %v, want phx_error", event, payload)
+	}
+}
+
+func TestEventRouter(t *testing.T) {
+	var r EventRouter
+	var got []int
+	HandleEventFunc(&r, "add", func(ctx context.Context, e incrementEvent) error {
+		got = append(got, e.By)
+		return nil
+	})
+	r.Handle("reset", func(ctx context.Context, e *Event) error {
+		got = nil
+		return nil
+	})
+	if names := EventNames(&r); !reflect.DeepEqual(names, []string{"add", "reset"}) {
+		t.Errorf("got events %v", names)
+	}
+	ctx := context.Background()
+	if err := r.HandleEvent(ctx, &Event{Type: "add", Kind: "hook", Raw: json.RawMessage(`{"by":7}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.HandleEvent(ctx, &Event{Type: "add", Kind: "form", Data: url.Values{"By": {"8"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []int{7, 8}) {
+		t.Errorf("got %v, want [7 8]", got)
+	}
+	if err := r.HandleEvent(ctx, &Event{Type: "nope"}); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("got error %v, want ErrUnknownEvent", err)
+	}
+}
+
+func TestRouteEventsHandle(t *testing.T) {
+	v := new(routedView)
+	r := RouteEvents(v)
+	r.Handle("save-draft", func(ctx context.Context, e *Event) error {
+		v.Key = "draft"
+		return nil
+	})
+	if got, want := EventNames(r), []string{"decrement", "increment", "key_update", "save-draft"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	ctx := context.Background()
+	if err := r.HandleEvent(ctx, &Event{Type: "save-draft"}); err != nil || v.Key != "draft" {
+		t.Fatalf("save-draft: got %v, key %q", err, v.Key)
+	}
+	if err := r.HandleEvent(ctx, &Event{Type: "increment", Kind: "hook", Raw: json.RawMessage(`{"by":4}`)}); err != nil || v.Count != 4 {
+		t.Fatalf("increment: got %v, count %d", err, v.Count)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{"Increment": "increment", "KeyUpdate": "key_update", "HTTPCall": "http_call", "Save2Draft": "save2_draft"} {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
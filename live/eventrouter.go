@@ -0,0 +1,234 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// ErrUnknownEvent is returned by EventRouter.HandleEvent for events with no handler.
+// It is wrapped with the event's name; use errors.Is to check for it.
+var ErrUnknownEvent = errors.New("live: unknown event")
+
+// EventRouter routes events to handlers by name, as an alternative to switching on
+// Event.Type in HandleEvent. It implements EventHandler, so it can be embedded in a View
+// or Component, or called from its HandleEvent for the events it doesn't handle itself.
+// The zero value is an EventRouter with no handlers.
+//
+// An EventRouter is not safe for concurrent modification; register its handlers
+// before the View is rendered, e.g. in its constructor or Mount.
+type EventRouter struct {
+	handlers map[string]func(context.Context, *Event) error
+	v        reflect.Value // whose event methods handle the events with no handler; see RouteEvents
+	methods  *eventMethods
+}
+
+// Handle registers fn to handle events with the given name, replacing any previous handler.
+func (r *EventRouter) Handle(name string, fn func(context.Context, *Event) error) {
+	if r.handlers == nil {
+		r.handlers = make(map[string]func(context.Context, *Event) error)
+	}
+	r.handlers[name] = fn
+}
+
+// HandleEventFunc registers fn to handle events with the given name on r, passing it the
+// event's value decoded into a T with Event.Decode, e.g.:
+//
+//	live.HandleEventFunc(&v.Events, "increment", func(ctx context.Context, e IncrementEvent) error {
+//		v.Count += e.By
+//		return nil
+//	})
+func HandleEventFunc[T any](r *EventRouter, name string, fn func(context.Context, T) error) {
+	r.Handle(name, func(ctx context.Context, e *Event) error {
+		var v T
+		err := e.Decode(&v)
+		if err != nil {
+			return fmt.Errorf("decoding %q event: %w", e.Type, err)
+		}
+		return fn(ctx, v)
+	})
+}
+
+// HandleEvent calls the handler registered for e, or else the event method for e
+// (see RouteEvents), returning an error wrapping ErrUnknownEvent if there is none.
+func (r *EventRouter) HandleEvent(ctx context.Context, e *Event) error {
+	if fn, ok := r.handlers[e.Type]; ok {
+		return fn(ctx, e)
+	}
+	if i, ok := r.methods.lookup(e.Type); ok {
+		return r.methods.list[i].call(ctx, r.v, e)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownEvent, e.Type)
+}
+
+// EventNames returns the sorted names of the events r handles.
+func (r *EventRouter) EventNames() []string {
+	names := maps.Keys(r.handlers)
+	for _, m := range r.methods.all() {
+		if _, ok := r.handlers[m.event]; !ok {
+			names = append(names, m.event)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// EventLister is an interface that can be implemented by a View or Component to list
+// the names of the events it handles, for tooling. EventRouter implements it.
+type EventLister interface {
+	EventNames() []string
+}
+
+// EventNames returns the sorted names of the events v accepts: those listed by its
+// EventNames method if it implements EventLister, or else those of its event methods
+// (see RouteEvents). It returns nil if v's events are not known.
+func EventNames(v any) []string {
+	if el, ok := v.(EventLister); ok {
+		return el.EventNames()
+	}
+	var names []string
+	for _, m := range eventMethodsOf(reflect.TypeOf(v)).all() {
+		names = append(names, m.event)
+	}
+	return names
+}
+
+// RouteEvents returns an EventRouter that routes events to v's event methods: exported
+// methods named Handle followed by the event's name in CamelCase (e.g. HandleKeyUpdate for
+// "key_update") that take a context.Context and either a *Event or a value to decode the
+// event into, and return an error. The lifecycle methods HandleEvent, HandleInfo,
+// HandleParams and HandleAsync are not event methods. For instance:
+//
+//	func (c *Counter) HandleEvent(ctx context.Context, e *live.Event) error {
+//		return live.RouteEvents(c).HandleEvent(ctx, e)
+//	}
+//
+//	func (c *Counter) HandleIncrement(ctx context.Context, e IncrementEvent) error {
+//		c.Count += e.By
+//		return nil
+//	}
+//
+// Every such method can be called by the client, with any value it likes: a client is not
+// limited to the events the View's templates send, so check an event's value as you would
+// a form's. Don't give other methods of v names and signatures like these.
+//
+// Only events named in snake_case are routed to methods. Register handlers for events
+// named otherwise, e.g. "save-draft", with Handle on the returned EventRouter; they take
+// precedence over event methods.
+//
+// The event methods of each type are only looked up once, so RouteEvents is cheap enough
+// to call for every event.
+func RouteEvents(v any) *EventRouter {
+	rv := reflect.ValueOf(v)
+	return &EventRouter{v: rv, methods: eventMethodsOf(rv.Type())}
+}
+
+// eventMethod is an event method of a type; see RouteEvents.
+type eventMethod struct {
+	event string
+	index int
+	arg   reflect.Type
+}
+
+// call calls the method of v, decoding e into its argument if needed.
+func (m *eventMethod) call(ctx context.Context, v reflect.Value, e *Event) error {
+	in := reflect.ValueOf(e)
+	if m.arg != in.Type() {
+		var err error
+		if m.arg.Kind() == reflect.Pointer {
+			in = reflect.New(m.arg.Elem())
+			err = e.Decode(in.Interface())
+		} else {
+			p := reflect.New(m.arg)
+			err = e.Decode(p.Interface())
+			in = p.Elem()
+		}
+		if err != nil {
+			return fmt.Errorf("decoding %q event: %w", e.Type, err)
+		}
+	}
+	out := v.Method(m.index).Call([]reflect.Value{reflect.ValueOf(ctx), in})
+	err, _ := out[0].Interface().(error)
+	return err
+}
+
+// eventMethods are the event methods of a type, sorted by event name.
+type eventMethods struct {
+	list []eventMethod
+}
+
+// all returns the event methods, or nil if ms is nil.
+func (ms *eventMethods) all() []eventMethod {
+	if ms == nil {
+		return nil
+	}
+	return ms.list
+}
+
+// lookup returns the index in ms.list of the method for the named event.
+func (ms *eventMethods) lookup(event string) (int, bool) {
+	if ms == nil {
+		return 0, false
+	}
+	return slices.BinarySearchFunc(ms.list, event, func(m eventMethod, event string) int {
+		return strings.Compare(m.event, event)
+	})
+}
+
+var (
+	eventMethodsCache sync.Map // reflect.Type -> *eventMethods
+	contextType       = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	lifecycleMethods  = []string{"HandleEvent", "HandleInfo", "HandleParams", "HandleAsync"}
+)
+
+// eventMethodsOf returns the event methods of t, or nil if t is nil.
+func eventMethodsOf(t reflect.Type) *eventMethods {
+	if t == nil {
+		return nil
+	}
+	if ms, ok := eventMethodsCache.Load(t); ok {
+		return ms.(*eventMethods)
+	}
+	ms := new(eventMethods)
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		name, ok := strings.CutPrefix(m.Name, "Handle")
+		if !ok || name == "" || slices.Contains(lifecycleMethods, m.Name) {
+			continue
+		}
+		mt := m.Type // includes the receiver
+		if mt.NumIn() != 3 || mt.In(1) != contextType || mt.NumOut() != 1 || mt.Out(0) != errorType {
+			continue
+		}
+		ms.list = append(ms.list, eventMethod{event: snakeCase(name), index: i, arg: mt.In(2)})
+	}
+	slices.SortFunc(ms.list, func(a, b eventMethod) int { return strings.Compare(a.event, b.event) })
+	eventMethodsCache.Store(t, ms)
+	return ms
+}
+
+// snakeCase converts a CamelCase name to snake_case, keeping acronyms together,
+// e.g. KeyUpdate to key_update and HTTPCall to http_call.
+func snakeCase(name string) string {
+	rs := []rune(name)
+	var b strings.Builder
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
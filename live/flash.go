@@ -0,0 +1,72 @@
+package live
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/exp/maps"
+)
+
+// flashCookie is the cookie the javascript client sets to carry
+// flash messages across a full page redirect.
+const flashCookie = "__phoenix_flash__"
+
+// PutFlash sets the flash message of the given kind (e.g. "info" or "error").
+// Flash messages are available to templates via Meta.Flash and LayoutDot.Flash
+// and survive Redirect and PushNav.
+func PutFlash(ctx context.Context, kind, msg string) {
+	s := socketValue(ctx)
+	if s == nil {
+		return
+	}
+	s.flash[kind] = msg
+}
+
+// ClearFlash clears the flash messages of the given kinds.
+// If no kinds are given, all flash messages are cleared.
+func ClearFlash(ctx context.Context, kinds ...string) {
+	s := socketValue(ctx)
+	if s == nil {
+		return
+	}
+	if len(kinds) == 0 {
+		maps.Clear(s.flash)
+		return
+	}
+	for _, k := range kinds {
+		delete(s.flash, k)
+	}
+}
+
+// flashToken returns a signed token carrying flash, or "" if flash is empty.
+func (c *Config) flashToken(flash map[string]string) (string, error) {
+	if len(flash) == 0 {
+		return "", nil
+	}
+	return c.signer().sign(flash)
+}
+
+// verifyFlash returns the flash messages carried by token.
+// Invalid tokens yield no flash messages.
+func (c *Config) verifyFlash(token string) map[string]string {
+	flash := make(map[string]string)
+	if token == "" {
+		return flash
+	}
+	err := c.signer().verify(token, &flash)
+	if err != nil {
+		return make(map[string]string)
+	}
+	return flash
+}
+
+// popFlashCookie returns the flash messages carried by r's flash cookie, if any,
+// and instructs the client to delete the cookie.
+func (c *Config) popFlashCookie(w http.ResponseWriter, r *http.Request) map[string]string {
+	cookie, err := r.Cookie(flashCookie)
+	if err != nil {
+		return make(map[string]string)
+	}
+	http.SetCookie(w, &http.Cookie{Name: flashCookie, Path: "/", MaxAge: -1})
+	return c.verifyFlash(cookie.Value)
+}
@@ -0,0 +1,73 @@
+package live
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+type flashView struct{}
+
+func (v *flashView) Mount(ctx context.Context, p Params) error {
+	PutFlash(ctx, "error", "Oops")
+	return nil
+}
+
+func (v *flashView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return meta, htmltmpl.Must(htmltmpl.New("flash").Parse(
+		`<p>{{ .Flash.info }}</p><p>{{ .Flash.error }}</p>`,
+	))
+}
+
+func TestFlashCookie(t *testing.T) {
+	c := newTestConfig(map[string]func() View{
+		"/flash": func() View { return new(flashView) },
+	})
+	h := c.Middleware(http.NotFoundHandler())
+
+	token, err := c.flashToken(map[string]string{"info": "Saved!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/flash", nil)
+	r.AddCookie(&http.Cookie{Name: flashCookie, Value: token})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "<p>Saved!</p><p>Oops</p>") {
+		t.Fatalf("flash not rendered in:\n%s", body)
+	}
+	if !strings.Contains(w.Header().Get("Set-Cookie"), flashCookie+"=;") {
+		t.Fatalf("flash cookie not cleared, got Set-Cookie %q", w.Header().Get("Set-Cookie"))
+	}
+
+	// The session carries the flash on to the websocket join.
+	_, rest, _ := strings.Cut(body, `data-phx-session="`)
+	sessToken, _, _ := strings.Cut(rest, `"`)
+	var sess session
+	if err := c.signer().verify(sessToken, &sess); err != nil {
+		t.Fatal(err)
+	}
+	if sess.Flash["info"] != "Saved!" || sess.Flash["error"] != "Oops" {
+		t.Fatalf("session flash = %v", sess.Flash)
+	}
+}
+
+func TestFlashCookieTampered(t *testing.T) {
+	c := newTestConfig(map[string]func() View{
+		"/flash": func() View { return new(flashView) },
+	})
+	h := c.Middleware(http.NotFoundHandler())
+	r := httptest.NewRequest("GET", "/flash", nil)
+	r.AddCookie(&http.Cookie{Name: flashCookie, Value: "e30.bogus"})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), "<p></p><p>Oops</p>") {
+		t.Fatalf("unexpected body:\n%s", w.Body.String())
+	}
+}
@@ -15,6 +15,7 @@ import (
 //   - liveFileInput: renders a file input tag for uploading files to a View
 //   - liveImgPreview: renders an image preview for file to be uploaded to a View
 //   - submitTag: renders a submit fuction that supports the PhxDisableWith feature
+//   - liveComponent: renders a stateful Component
 func Funcs() htmltmpl.FuncMap {
 	return htmltmpl.FuncMap{
 		"liveTitleTag":         TitleTag,
@@ -23,6 +24,7 @@ func Funcs() htmltmpl.FuncMap {
 		"liveFileInputTag":     FileInputTag,
 		"liveImgPreviewTag":    ImagePreviewTag,
 		"submitTag":            SubmitTag,
+		"liveComponent":        ComponentTag,
 	}
 }
 
@@ -79,15 +81,31 @@ func Navigation(linkType, path string, params map[string]any, text string) (html
 }
 
 // LiveView renders a container for a live.View - required for layoutTemplates.
+// In a layout with a nested layout (see Layout), it renders the nested layout instead.
 func LiveViewTag(ld *LayoutDot) (htmltmpl.HTML, error) {
+	if len(ld.inner) > 0 {
+		inner := *ld
+		inner.inner = ld.inner[1:]
+		dot, t := ld.inner[0](ld.w, ld.r, &inner)
+		var buf strings.Builder
+		err := t.Execute(&buf, dot)
+		if err != nil {
+			return "", err
+		}
+		return htmltmpl.HTML(buf.String()), nil
+	}
+	sess, err := ld.sessionToken()
+	if err != nil {
+		return "", err
+	}
 	var buf strings.Builder
 	buf.WriteString(fmt.Sprintf(`
 		<div
 			data-phx-main="true"
-			data-phx-session=""
+			data-phx-session="%s"
 			data-phx-static="%s"
-			id="phx-%s">`, ld.Static, ld.LiveViewID))
-	err := ld.ExecuteViewTemplate(&buf)
+			id="phx-%s">`, sess, ld.Static, ld.LiveViewID))
+	err = ld.ExecuteViewTemplate(&buf)
 	if err != nil {
 		return "", err
 	}
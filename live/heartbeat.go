@@ -0,0 +1,65 @@
+package live
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Defaults of the Config fields bounding a websocket connection.
+const (
+	// defaultHeartbeatTimeout is the default value of Config.HeartbeatTimeout.
+	// The client sends a heartbeat every 30s.
+	defaultHeartbeatTimeout = 60 * time.Second
+	// defaultWriteTimeout is the default value of Config.WriteTimeout.
+	defaultWriteTimeout = 10 * time.Second
+	// defaultMaxMessageSize is the default value of Config.MaxMessageSize.
+	defaultMaxMessageSize = 1 << 20
+)
+
+// heartbeatTimeout returns how long a socket may go without hearing from its client.
+func (c *Config) heartbeatTimeout() time.Duration {
+	if c.HeartbeatTimeout > 0 {
+		return c.HeartbeatTimeout
+	}
+	return defaultHeartbeatTimeout
+}
+
+// pingInterval returns the interval between pings, or 0 if no pings are sent.
+func (c *Config) pingInterval() time.Duration {
+	switch {
+	case c.PingInterval > 0:
+		return c.PingInterval
+	case c.PingInterval < 0:
+		return 0
+	}
+	return c.heartbeatTimeout() / 2
+}
+
+// writeTimeout returns how long writing a message to a socket may take.
+func (c *Config) writeTimeout() time.Duration {
+	if c.WriteTimeout > 0 {
+		return c.WriteTimeout
+	}
+	return defaultWriteTimeout
+}
+
+// maxMessageSize returns the size in bytes of the largest message a client may send.
+func (c *Config) maxMessageSize() int64 {
+	if c.MaxMessageSize > 0 {
+		return c.MaxMessageSize
+	}
+	return defaultMaxMessageSize
+}
+
+// keepAlive pushes back the socket's read deadline, having heard from the client.
+// If nothing else arrives within Config.HeartbeatTimeout, reading fails and
+// the socket is torn down with ErrHeartbeatTimeout.
+func (s *socket) keepAlive() error {
+	return s.conn.SetReadDeadline(time.Now().Add(s.config.heartbeatTimeout()))
+}
+
+// ping sends a ping control frame, which the client's browser answers with a pong.
+func (s *socket) ping() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.config.writeTimeout()))
+}
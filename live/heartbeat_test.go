@@ -0,0 +1,103 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+// idleView records the reasons it terminates with.
+type idleView struct {
+	terminated chan error
+}
+
+func (v *idleView) Terminate(ctx context.Context, reason error) {
+	v.terminated <- reason
+}
+
+func (v *idleView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("idle").Parse(`<p>idle</p>`))
+}
+
+// joinIdleView joins a new idleView, served with c's limits, over a new connection.
+func joinIdleView(t *testing.T, limits func(*Config)) (*idleView, *testConn, testPage) {
+	t.Helper()
+	v := &idleView{terminated: make(chan error, 1)}
+	c := newTestConfig(map[string]func() View{
+		"/idle": func() View { return v },
+	})
+	limits(c)
+	srv := newTestServer(t, c)
+	page := getPage(t, srv, "/idle")
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join: got %s %v", event, payload)
+	}
+	return v, conn, page
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	v, _, _ := joinIdleView(t, func(c *Config) {
+		c.HeartbeatTimeout = 50 * time.Millisecond
+		c.PingInterval = -1
+	})
+	reason := wait(t, v.terminated, "Terminate")
+	if !errors.Is(reason, ErrDisconnected) || !errors.Is(reason, ErrHeartbeatTimeout) {
+		t.Errorf("got reason %v, want heartbeat timeout", reason)
+	}
+}
+
+func TestHeartbeatKeepsAlive(t *testing.T) {
+	v, conn, _ := joinIdleView(t, func(c *Config) {
+		c.HeartbeatTimeout = 100 * time.Millisecond
+		c.PingInterval = -1
+	})
+	for i := 0; i < 5; i++ {
+		time.Sleep(40 * time.Millisecond)
+		conn.send("phoenix", "heartbeat", map[string]any{})
+		if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+			t.Fatalf("heartbeat: got %s %v", event, payload)
+		}
+	}
+	select {
+	case reason := <-v.terminated:
+		t.Fatalf("terminated with %v while sending heartbeats", reason)
+	default:
+	}
+}
+
+func TestPingKeepsAlive(t *testing.T) {
+	v, conn, _ := joinIdleView(t, func(c *Config) {
+		c.HeartbeatTimeout = 100 * time.Millisecond
+		c.PingInterval = 20 * time.Millisecond
+	})
+	// reading answers the server's pings, but no message arrives
+	conn.conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	if _, _, err := conn.conn.ReadMessage(); err == nil {
+		t.Fatal("got a message, want none")
+	}
+	select {
+	case reason := <-v.terminated:
+		t.Fatalf("terminated with %v while answering pings", reason)
+	default:
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	v, conn, page := joinIdleView(t, func(c *Config) {
+		c.MaxMessageSize = 512
+	})
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": strings.Repeat("x", 1024)})
+	conn.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.conn.ReadMessage(); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("got %v, want the connection closed", err)
+	}
+	if reason := wait(t, v.terminated, "Terminate"); !errors.Is(reason, ErrDisconnected) {
+		t.Errorf("got reason %v, want disconnected", reason)
+	}
+}
@@ -0,0 +1,93 @@
+// Package livetest helps test packages built on live with Views served over a real websocket.
+package livetest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+	"github.com/canopyclimate/golive/live"
+	"github.com/gorilla/websocket"
+)
+
+// NewServer serves the Views set by the handlers of mux (see live.SetView) over HTTP,
+// and their websocket at /live/websocket, until the test ends.
+func NewServer(t *testing.T, mux *http.ServeMux) *httptest.Server {
+	t.Helper()
+	layout := htmltmpl.Must(htmltmpl.New("layout").Funcs(live.Funcs()).Parse(
+		`<meta name="csrf-token" content="{{ .CSRFToken }}" />{{ liveViewContainerTag . }}`,
+	))
+	c := live.Config{
+		Mux: mux,
+		RenderLayout: func(w http.ResponseWriter, r *http.Request, ld *live.LayoutDot) (any, *htmltmpl.Template) {
+			return ld, layout
+		},
+	}
+	srvMux := http.NewServeMux()
+	srvMux.Handle("/live/websocket", live.NewWebsocketHandler(c))
+	srvMux.Handle("/", c.Middleware(http.NotFoundHandler()))
+	srv := httptest.NewServer(srvMux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var attrRE = regexp.MustCompile(`(id|data-phx-session|content)="([^"]*)"`)
+
+// Join renders the View at path over HTTP and joins it over a new websocket,
+// which is closed when the test ends.
+func Join(t *testing.T, srv *httptest.Server, path string) *websocket.Conn {
+	t.Helper()
+	res, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := map[string]string{}
+	for _, m := range attrRE.FindAllStringSubmatch(string(body), -1) {
+		attrs[m[1]] = m[2]
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/live/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	err = conn.WriteJSON([]any{"1", "1", "lv:" + attrs["id"], "phx_join", map[string]any{
+		"url":     srv.URL + path,
+		"params":  map[string]any{"_csrf_token": attrs["content"], "_mounts": 0},
+		"session": attrs["data-phx-session"],
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event, payload := Recv(t, conn); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join failed: %s %v", event, payload)
+	}
+	return conn
+}
+
+// Recv reads a message from conn and returns its event and payload.
+func Recv(t *testing.T, conn *websocket.Conn) (string, map[string]any) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg []json.RawMessage
+	err := conn.ReadJSON(&msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event string
+	var payload map[string]any
+	json.Unmarshal(msg[3], &event)
+	json.Unmarshal(msg[4], &payload)
+	return event, payload
+}
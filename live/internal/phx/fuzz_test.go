@@ -32,3 +32,12 @@ func FuzzPhxParse(f *testing.F) {
 		}
 	})
 }
+
+func FuzzPhxParseHeader(f *testing.F) {
+	f.Fuzz(func(t *testing.T, in []byte) {
+		msg, err := ParseHeader(in)
+		if msg == nil && err == nil {
+			panic("no msg, no err")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package phx
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// MergeDiffs merges the messages a and b, both diffs pushed to the same topic, into one
+// diff that the client applies as if it had applied a then b, so that a client catching
+// up patches its DOM once. It reports false, leaving a and b to be sent one after the other,
+// if they are not such diffs, or if b cannot be folded into a, i.e. if a pushes events,
+// either carries a stream, or their components share statics (see Rendered.mergeDiff in
+// phoenix_live_view.js).
+func MergeDiffs(a, b []byte) ([]byte, bool) {
+	da, ok := parseDiff(a)
+	if !ok {
+		return nil, false
+	}
+	db, ok := parseDiff(b)
+	if !ok {
+		return nil, false
+	}
+	if !equalRefs(da.JoinRef, db.JoinRef) || da.Topic != db.Topic {
+		return nil, false
+	}
+	var pa, pb map[string]any
+	if !decodeDiff(da.Payload, &pa) || !decodeDiff(db.Payload, &pb) {
+		return nil, false
+	}
+	if _, ok := pa["e"]; ok {
+		// events are pushed once a's diff is applied, before b's
+		return nil, false
+	}
+	if hasStream(pa) || hasStream(pb) || sharesStatics(pa) || sharesStatics(pb) {
+		return nil, false
+	}
+
+	ca, _ := pa["c"].(map[string]any)
+	cb, _ := pb["c"].(map[string]any)
+	delete(pb, "c")
+	pa = mergeTree(pa, pb).(map[string]any)
+	if cb != nil {
+		if ca == nil {
+			ca = make(map[string]any, len(cb))
+		}
+		for cid, c := range cb {
+			ca[cid] = mergeTree(ca[cid], c)
+		}
+		pa["c"] = ca
+	}
+	payload, err := json.Marshal(pa)
+	if err != nil {
+		return nil, false
+	}
+	m, err := NewDiff(da.JoinRef, da.Topic, payload).JSON()
+	if err != nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// parseDiff parses m if it is a diff pushed to the client, as opposed to a reply.
+func parseDiff(m []byte) (*Diff, bool) {
+	var elems []json.RawMessage
+	err := json.Unmarshal(m, &elems)
+	if err != nil || len(elems) != 5 {
+		return nil, false
+	}
+	var d Diff
+	var event string
+	if json.Unmarshal(elems[0], &d.JoinRef) != nil ||
+		json.Unmarshal(elems[1], &d.MsgRef) != nil ||
+		json.Unmarshal(elems[2], &d.Topic) != nil ||
+		json.Unmarshal(elems[3], &event) != nil {
+		return nil, false
+	}
+	if event != "diff" || d.MsgRef != nil {
+		return nil, false
+	}
+	d.Event = event
+	d.Payload = elems[4]
+	return &d, true
+}
+
+// decodeDiff decodes the payload of a diff into v, keeping its numbers as sent.
+func decodeDiff(payload []byte, v *map[string]any) bool {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	return dec.Decode(v) == nil && *v != nil
+}
+
+func equalRefs(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// mergeTree merges the rendered tree b into a, as the client does: a tree with
+// statics replaces the previous one, otherwise its dynamics are merged one by one.
+func mergeTree(a, b any) any {
+	mb, ok := b.(map[string]any)
+	if !ok {
+		return b
+	}
+	if _, ok := mb["s"]; ok {
+		return b
+	}
+	ma, ok := a.(map[string]any)
+	if !ok {
+		return b
+	}
+	for k, v := range mb {
+		ma[k] = mergeTree(ma[k], v)
+	}
+	return ma
+}
+
+// hasStream reports whether the rendered tree v carries a stream, whose inserts and
+// deletes are applied by the client diff by diff rather than merged.
+func hasStream(v any) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		if _, ok := v["stream"]; ok {
+			return true
+		}
+		for _, x := range v {
+			if hasStream(x) {
+				return true
+			}
+		}
+	case []any:
+		for _, x := range v {
+			if hasStream(x) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sharesStatics reports whether a component of the diff p refers to the statics of
+// another component by its CID, which may mean another one once diffs are merged.
+func sharesStatics(p map[string]any) bool {
+	cs, _ := p["c"].(map[string]any)
+	for _, c := range cs {
+		c, _ := c.(map[string]any)
+		if _, ok := c["s"].(json.Number); ok {
+			return true
+		}
+	}
+	return false
+}
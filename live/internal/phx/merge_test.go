@@ -0,0 +1,95 @@
+package phx
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergeDiffs(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string // "" if not merged
+	}{
+		{
+			"dynamics",
+			`[null,null,"lv:1","diff",{"0":"a","1":{"0":"x","1":"y"}}]`,
+			`[null,null,"lv:1","diff",{"0":"b","1":{"1":"z"},"2":"c","t":"Title"}]`,
+			`[null,null,"lv:1","diff",{"0":"b","1":{"0":"x","1":"z"},"2":"c","t":"Title"}]`,
+		},
+		{
+			"statics replace",
+			`[null,null,"lv:1","diff",{"0":{"0":"x","1":"y"}}]`,
+			`[null,null,"lv:1","diff",{"0":{"0":"z","s":["<p>","</p>"]}}]`,
+			`[null,null,"lv:1","diff",{"0":{"0":"z","s":["<p>","</p>"]}}]`,
+		},
+		{
+			"comprehensions replace",
+			`[null,null,"lv:1","diff",{"0":{"d":[["a"],["b"]]}}]`,
+			`[null,null,"lv:1","diff",{"0":{"d":[["c"]]}}]`,
+			`[null,null,"lv:1","diff",{"0":{"d":[["c"]]}}]`,
+		},
+		{
+			"components",
+			`[null,null,"lv:1","diff",{"c":{"1":{"0":"a","1":"b"}}}]`,
+			`[null,null,"lv:1","diff",{"0":"x","c":{"1":{"1":"c"},"2":{"0":"d","s":["",""]}}}]`,
+			`[null,null,"lv:1","diff",{"0":"x","c":{"1":{"0":"a","1":"c"},"2":{"0":"d","s":["",""]}}}]`,
+		},
+		{
+			"events last",
+			`[null,null,"lv:1","diff",{"0":"a"}]`,
+			`[null,null,"lv:1","diff",{"0":"b","e":[["ping",{}]]}]`,
+			`[null,null,"lv:1","diff",{"0":"b","e":[["ping",{}]]}]`,
+		},
+		{
+			"events first",
+			`[null,null,"lv:1","diff",{"0":"a","e":[["ping",{}]]}]`,
+			`[null,null,"lv:1","diff",{"0":"b"}]`,
+			"",
+		},
+		{
+			"stream",
+			`[null,null,"lv:1","diff",{"0":{"d":[],"stream":[[],[]]}}]`,
+			`[null,null,"lv:1","diff",{"0":"b"}]`,
+			"",
+		},
+		{
+			"shared statics",
+			`[null,null,"lv:1","diff",{"c":{"1":{"0":"a","s":["",""]}}}]`,
+			`[null,null,"lv:1","diff",{"c":{"2":{"0":"b","s":1}}}]`,
+			"",
+		},
+		{
+			"topics",
+			`[null,null,"lv:1","diff",{"0":"a"}]`,
+			`[null,null,"lv:2","diff",{"0":"b"}]`,
+			"",
+		},
+		{
+			"reply",
+			`[null,null,"lv:1","diff",{"0":"a"}]`,
+			`["1","2","lv:1","phx_reply",{"response":{"diff":{"0":"b"}},"status":"ok"}]`,
+			"",
+		},
+	}
+	for _, test := range tests {
+		got, ok := MergeDiffs([]byte(test.a), []byte(test.b))
+		if test.want == "" {
+			if ok {
+				t.Errorf("%s: merged into %s, want not merged", test.name, got)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: not merged, want %s", test.name, test.want)
+			continue
+		}
+		var g, w any
+		json.Unmarshal(got, &g)
+		json.Unmarshal([]byte(test.want), &w)
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
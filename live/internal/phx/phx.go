@@ -1,6 +1,7 @@
 package phx
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 )
@@ -11,18 +12,28 @@ type Msg struct {
 	Topic   string
 	Event   string
 	Payload map[string]any
+	// RawPayload is Payload as sent, for decoding parts of it into typed values.
+	RawPayload json.RawMessage
+	// Size is the length of the message as sent, in bytes.
+	Size int
 }
 
 func Parse(msg []byte) (*Msg, error) {
-	var raw []any
-	err := json.Unmarshal(msg, &raw)
+	var elems []json.RawMessage
+	err := json.Unmarshal(msg, &elems)
 	if err != nil {
 		return nil, err
 	}
 
 	// messages are always arrays of 5 elements
-	if len(raw) != 5 {
-		return nil, fmt.Errorf("phx message must contain 5 elements, got %d: %v", len(raw), raw)
+	if len(elems) != 5 {
+		return nil, fmt.Errorf("phx message must contain 5 elements, got %d: %s", len(elems), msg)
+	}
+	raw := make([]any, len(elems))
+	for i, e := range elems {
+		if err := json.Unmarshal(e, &raw[i]); err != nil {
+			return nil, err
+		}
 	}
 
 	var strings [4]string
@@ -46,11 +57,48 @@ func Parse(msg []byte) (*Msg, error) {
 	pm := &Msg{
 		// Note: Docs say JoinRef can be nil, but type doesn't allow for it.
 		// JoinRef can be the empty string here, however, if it's nil in raw.
+		JoinRef:    strings[0],
+		MsgRef:     strings[1],
+		Topic:      strings[2],
+		Event:      strings[3],
+		Payload:    payload,
+		RawPayload: elems[4],
+		Size:       len(msg),
+	}
+	return pm, nil
+}
+
+// ParseHeader parses the refs, topic and event of msg, leaving its payload undecoded,
+// e.g. to reply to a message too large to be parsed.
+func ParseHeader(msg []byte) (*Msg, error) {
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('[') {
+		return nil, fmt.Errorf("phx message must be an array, got: %v", tok)
+	}
+	var strings [4]string
+	for i := range strings {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch x := tok.(type) {
+		case nil:
+		case string:
+			strings[i] = x
+		default:
+			return nil, fmt.Errorf("invalid format for element %d, got: %T", i, x)
+		}
+	}
+	pm := &Msg{
 		JoinRef: strings[0],
 		MsgRef:  strings[1],
 		Topic:   strings[2],
 		Event:   strings[3],
-		Payload: payload,
+		Size:    len(msg),
 	}
 	return pm, nil
 }
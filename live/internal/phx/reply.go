@@ -3,11 +3,14 @@ package phx
 import "encoding/json"
 
 type Response struct {
-	Rendered json.RawMessage `json:"rendered,omitempty"`
-	Diff     json.RawMessage `json:"diff,omitempty"`
-	Config   json.RawMessage `json:"config,omitempty"`
-	Entries  json.RawMessage `json:"entries,omitempty"`
-	Redirect json.RawMessage `json:"redirect,omitempty"`
+	Rendered     json.RawMessage `json:"rendered,omitempty"`
+	Diff         json.RawMessage `json:"diff,omitempty"`
+	Config       json.RawMessage `json:"config,omitempty"`
+	Entries      json.RawMessage `json:"entries,omitempty"`
+	Redirect     json.RawMessage `json:"redirect,omitempty"`
+	CIDs         []int           `json:"cids,omitempty"`
+	Reason       string          `json:"reason,omitempty"`
+	LinkRedirect bool            `json:"link_redirect,omitempty"`
 }
 
 type Payload struct {
@@ -32,12 +35,14 @@ type Diff struct {
 }
 
 type Redirect struct {
-	To string `json:"to,omitempty"`
+	To    string `json:"to,omitempty"`
+	Flash string `json:"flash,omitempty"`
 }
 
 type NavPayload struct {
-	To   string `json:"to,omitempty"`
-	Kind string `json:"kind,omitempty"`
+	To    string `json:"to,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	Flash string `json:"flash,omitempty"`
 }
 
 type Nav struct {
@@ -124,6 +129,61 @@ func NewReplyDiff(msg Msg, diff []byte) *Reply {
 	}
 }
 
+// NewLinkRedirectReply answers a live_patch to a URL of another View,
+// which the client then live redirects to.
+func NewLinkRedirectReply(msg Msg) *Reply {
+	return &Reply{
+		JoinRef: &msg.JoinRef,
+		MsgRef:  &msg.MsgRef,
+		Topic:   msg.Topic,
+		Event:   "phx_reply",
+		Payload: Payload{
+			Status: "ok",
+			Response: Response{
+				LinkRedirect: true,
+			},
+		},
+	}
+}
+
+func NewCIDsReply(msg Msg, cids []int) *Reply {
+	return &Reply{
+		JoinRef: &msg.JoinRef,
+		MsgRef:  &msg.MsgRef,
+		Topic:   msg.Topic,
+		Event:   "phx_reply",
+		Payload: Payload{
+			Status: "ok",
+			Response: Response{
+				CIDs: cids,
+			},
+		},
+	}
+}
+
+// NewErrorReply replies to msg with an error for reason.
+func NewErrorReply(msg Msg, reason string) *Reply {
+	return &Reply{
+		JoinRef: &msg.JoinRef,
+		MsgRef:  &msg.MsgRef,
+		Topic:   msg.Topic,
+		Event:   "phx_reply",
+		Payload: Payload{
+			Status: "error",
+			Response: Response{
+				Reason: reason,
+			},
+		},
+	}
+}
+
+// NewJoinRedirect rejects a join, having the client load to instead.
+func NewJoinRedirect(msg Msg, to string) *Reply {
+	r := NewRedirect(msg, to, "")
+	r.Payload.Status = "error"
+	return r
+}
+
 func NewRendered(msg Msg, rendered []byte) *Reply {
 	return &Reply{
 		JoinRef: &msg.JoinRef,
@@ -150,9 +210,10 @@ func NewHeartbeat(msgRef string) *Reply {
 	}
 }
 
-func NewRedirect(msg Msg, to string) *Reply {
+func NewRedirect(msg Msg, to string, flash string) *Reply {
 	redirect, err := json.Marshal(Redirect{
-		To: to,
+		To:    to,
+		Flash: flash,
 	})
 	if err != nil {
 		// to is created by calling String() on a url.URL, so it should always be valid
@@ -0,0 +1,92 @@
+package live
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+// A LayoutFunc returns the dot and template of a layout to render a View in.
+// The template should render the LayoutDot with liveViewContainerTag; see Config.RenderLayout.
+type LayoutFunc func(http.ResponseWriter, *http.Request, *LayoutDot) (any, *htmltmpl.Template)
+
+// Layout is a named layout a View can be rendered in; see Layouter.
+type Layout struct {
+	// Name identifies the layout. Live navigation between Views with different
+	// layouts reloads the page, so that the new View is rendered in its own.
+	Name string
+	// Render renders the layout. If nil, the Config's RenderLayout is used.
+	Render LayoutFunc
+	// Parent, if non-nil, is the layout this layout is nested in, such as a root layout
+	// rendering the page's <html> around an app layout. In the parent layout,
+	// liveViewContainerTag renders this layout, which renders the View's container.
+	Parent *Layout
+}
+
+// Layouter is an interface that can be implemented by a View to be rendered in its own
+// layout, instead of that of Config.RenderLayout. The layout is only rendered for the
+// initial HTTP request, outside the View's container.
+type Layouter interface {
+	Layout() *Layout
+}
+
+// defaultLayout is the layout used by DefaultLayout.
+var defaultLayout = htmltmpl.Must(htmltmpl.New("defaultLayout").Funcs(Funcs()).Parse(`<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <meta name="csrf-token" content="{{ .CSRFToken }}" />
+    {{ liveTitleTag .PageTitle }}
+    <script defer type="text/javascript" src="/js/index.js"></script>
+  </head>
+  <body>
+    {{ liveViewContainerTag . }}
+  </body>
+</html>`))
+
+// DefaultLayout renders a minimal HTML page with the CSRF token meta tag, a liveTitleTag,
+// the LiveView client script (served from /js/index.js, as built by cmd/buildjs) and the
+// View's container. It is used when Config.RenderLayout is nil.
+func DefaultLayout(w http.ResponseWriter, r *http.Request, ld *LayoutDot) (any, *htmltmpl.Template) {
+	return ld, defaultLayout
+}
+
+// layouts returns the layouts to render v in, outermost first.
+func (c *Config) layouts(v View) []LayoutFunc {
+	root := c.RenderLayout
+	if root == nil {
+		root = DefaultLayout
+	}
+	l, ok := v.(Layouter)
+	if !ok {
+		return []LayoutFunc{root}
+	}
+	var fns []LayoutFunc
+	for l := l.Layout(); l != nil; l = l.Parent {
+		fn := l.Render
+		if fn == nil {
+			fn = root
+		}
+		fns = append([]LayoutFunc{fn}, fns...)
+	}
+	if len(fns) == 0 {
+		fns = append(fns, root)
+	}
+	return fns
+}
+
+// layoutName returns the names of the layouts v is rendered in, outermost first,
+// joined by slashes, or "" for the Config's RenderLayout.
+func layoutName(v View) string {
+	l, ok := v.(Layouter)
+	if !ok {
+		return ""
+	}
+	var names []string
+	for l := l.Layout(); l != nil; l = l.Parent {
+		names = append([]string{l.Name}, names...)
+	}
+	return strings.Join(names, "/")
+}
@@ -0,0 +1,95 @@
+package live
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+type plainView struct{}
+
+func (v *plainView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	PageTitle(ctx, "Plain")
+	return v, htmltmpl.Must(htmltmpl.New("plain").Parse(`<p>plain</p>`))
+}
+
+func TestDefaultLayout(t *testing.T) {
+	c := newTestConfig(map[string]func() View{
+		"/plain": func() View { return new(plainView) },
+	})
+	c.RenderLayout = nil
+	srv := newTestServer(t, c)
+	page := getPage(t, srv, "/plain")
+	for _, want := range []string{
+		`<meta name="csrf-token" content="` + page.csrf + `" />`,
+		`<title>Plain</title>`,
+		`<script defer type="text/javascript" src="/js/index.js"></script>`,
+		`data-phx-main="true"`,
+		`<p>plain</p>`,
+	} {
+		if !strings.Contains(page.body, want) {
+			t.Errorf("page does not contain %s:\n%s", want, page.body)
+		}
+	}
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join: got %s %v", event, payload)
+	}
+}
+
+func testLayout(name string) LayoutFunc {
+	t := htmltmpl.Must(htmltmpl.New(name).Funcs(Funcs()).Parse(
+		`<` + name + `><meta name="csrf-token" content="{{ .CSRFToken }}" />{{ liveViewContainerTag . }}</` + name + `>`,
+	))
+	return func(w http.ResponseWriter, r *http.Request, ld *LayoutDot) (any, *htmltmpl.Template) {
+		return ld, t
+	}
+}
+
+var adminLayout = &Layout{
+	Name:   "admin",
+	Render: testLayout("admin"),
+	Parent: &Layout{Name: "root", Render: testLayout("root")},
+}
+
+type adminView struct{ plainView }
+
+func (v *adminView) Layout() *Layout { return adminLayout }
+
+func TestNestedLayouts(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/admin": func() View { return new(adminView) },
+	}))
+	page := getPage(t, srv, "/admin")
+	if !regexp.MustCompile(`(?s)^<root><meta[^>]*/><admin><meta[^>]*/>\s*<div[^>]*data-phx-main="true".*<p>plain</p></div></admin></root>$`).MatchString(page.body) {
+		t.Fatalf("got page %s", page.body)
+	}
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join: got %s %v", event, payload)
+	}
+}
+
+func TestLayoutChangeReloads(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/plain": func() View { return new(plainView) },
+		"/admin": func() View { return new(adminView) },
+	}))
+	page := getPage(t, srv, "/plain")
+	conn := dial(t, srv)
+	conn.send(page.topic, "phx_join", map[string]any{
+		"redirect": srv.URL + "/admin",
+		"params":   map[string]any{"_csrf_token": page.csrf},
+		"session":  page.session,
+	})
+	event, payload := conn.recv()
+	resp, _ := payload["response"].(map[string]any)
+	redirect, _ := resp["redirect"].(map[string]any)
+	if event != "phx_reply" || payload["status"] != "error" || redirect["to"] != srv.URL+"/admin" {
+		t.Fatalf("live redirect to another layout: got %s %v, want redirect", event, payload)
+	}
+}
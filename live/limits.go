@@ -0,0 +1,99 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/canopyclimate/golive/live/internal/phx"
+)
+
+// Errors a client's message can exceed a socket's limits with; see Config.LimitPolicy.
+// They are wrapped with detail; use errors.Is to check for them.
+var (
+	// ErrEventRate reports that a client sent messages faster than Config.EventRate allows.
+	ErrEventRate = errors.New("live: event rate exceeded")
+	// ErrPayloadSize reports that a message was larger than Config.MaxPayloadSize.
+	ErrPayloadSize = errors.New("live: payload too large")
+	// ErrFormFields reports that a form event had more fields than Config.MaxFormFields.
+	ErrFormFields = errors.New("live: too many form fields")
+)
+
+// LimitPolicy determines what happens to a client's message that exceeds the limits
+// of its socket (Config's EventRate, MaxPayloadSize and MaxFormFields).
+type LimitPolicy int
+
+const (
+	// ReplyOverLimit discards the message, replying to it with an error, so that the client
+	// stops waiting for it, e.g. removes the phx-click-loading class of the clicked element.
+	ReplyOverLimit LimitPolicy = iota
+	// DropOverLimit discards the message. The client gets no reply to it.
+	DropOverLimit
+	// DisconnectOverLimit closes the websocket, terminating its View with ErrDisconnected.
+	DisconnectOverLimit
+)
+
+// limitReasons are the reasons of the error replies of ReplyOverLimit.
+var limitReasons = map[error]string{
+	ErrEventRate:   "rate_limited",
+	ErrPayloadSize: "payload_too_large",
+	ErrFormFields:  "too_many_fields",
+}
+
+// checkLimits returns an error wrapping ErrEventRate, ErrPayloadSize or ErrFormFields
+// if msg exceeds the socket's limits, and nil if it may be handled.
+// The payload of a message over MaxPayloadSize is not decoded, so that is checked first.
+func (s *socket) checkLimits(msg *phx.Msg) error {
+	if limit := s.config.MaxPayloadSize; limit > 0 && msg.Size > limit {
+		return fmt.Errorf("%w: %s message of %d bytes, limit %d", ErrPayloadSize, msg.Event, msg.Size, limit)
+	}
+	switch msg.Event {
+	case "heartbeat", "phx_leave":
+		return nil
+	}
+	err := s.checkRate()
+	if err != nil {
+		return err
+	}
+	if msg.Event != "event" {
+		return nil
+	}
+	if limit := s.config.MaxFormFields; limit > 0 && msg.Payload["type"] == "form" {
+		value, _ := msg.Payload["value"].(string)
+		if n := strings.Count(value, "&") + 1; value != "" && n > limit {
+			return fmt.Errorf("%w: %d fields, limit %d", ErrFormFields, n, limit)
+		}
+	}
+	return nil
+}
+
+// checkRate returns an error wrapping ErrEventRate if the client is over Config.EventRate.
+func (s *socket) checkRate() error {
+	if s.eventLimiter != nil && !s.eventLimiter.Allow() {
+		return fmt.Errorf("%w: limit %v messages/s", ErrEventRate, s.config.EventRate)
+	}
+	return nil
+}
+
+// limitExceeded reports that msg exceeded the socket's limits with err, and returns the
+// reply to send according to Config.LimitPolicy: an error reply, nil to drop msg silently,
+// or an error wrapping ErrDisconnected to close the socket.
+func (s *socket) limitExceeded(ctx context.Context, msg *phx.Msg, err error) ([]byte, error) {
+	if s.config.OnLimitExceeded != nil {
+		s.config.OnLimitExceeded(ctx, s.view, err)
+	}
+	switch s.config.LimitPolicy {
+	case DropOverLimit:
+		return nil, nil
+	case DisconnectOverLimit:
+		return nil, fmt.Errorf("%w: %w", ErrDisconnected, err)
+	}
+	reason := "limit_exceeded"
+	for e, r := range limitReasons {
+		if errors.Is(err, e) {
+			reason = r
+		}
+	}
+	return phx.NewErrorReply(*msg, reason).JSON()
+}
@@ -0,0 +1,139 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// joinLimitedView joins a new panicView, served with c's limits, over a new connection.
+// It returns the errors passed to OnLimitExceeded.
+func joinLimitedView(t *testing.T, limits func(*Config)) (*panicView, *testConn, testPage, chan error) {
+	t.Helper()
+	v := new(panicView)
+	c := newTestConfig(map[string]func() View{
+		"/limited": func() View { return v },
+	})
+	exceeded := make(chan error, 10)
+	c.OnLimitExceeded = func(ctx context.Context, lv View, err error) {
+		if lv != v {
+			t.Errorf("OnLimitExceeded got View %v", lv)
+		}
+		exceeded <- err
+	}
+	limits(c)
+	srv := newTestServer(t, c)
+	page := getPage(t, srv, "/limited")
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join: got %s %v", event, payload)
+	}
+	return v, conn, page, exceeded
+}
+
+func TestEventRate(t *testing.T) {
+	_, conn, page, exceeded := joinLimitedView(t, func(c *Config) {
+		c.EventRate = rate.Every(time.Hour)
+		c.EventBurst = 3
+	})
+	// the join counts too
+	click := map[string]any{"type": "click", "event": "inc", "value": map[string]any{}}
+	for i := 0; i < 2; i++ {
+		conn.send(page.topic, "event", click)
+		if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+			t.Fatalf("event %d: got %s %v", i, event, payload)
+		}
+	}
+	conn.send(page.topic, "event", click)
+	event, payload := conn.recv()
+	resp, _ := payload["response"].(map[string]any)
+	if event != "phx_reply" || payload["status"] != "error" || resp["reason"] != "rate_limited" {
+		t.Fatalf("event over the rate: got %s %v, want rate limited", event, payload)
+	}
+	if err := wait(t, exceeded, "OnLimitExceeded"); !errors.Is(err, ErrEventRate) {
+		t.Errorf("OnLimitExceeded got %v, want ErrEventRate", err)
+	}
+	// nor are other messages
+	conn.send(page.topic, "live_patch", map[string]any{"url": page.url + "?q=1"})
+	event, payload = conn.recv()
+	resp, _ = payload["response"].(map[string]any)
+	if event != "phx_reply" || payload["status"] != "error" || resp["reason"] != "rate_limited" {
+		t.Fatalf("patch over the rate: got %s %v, want rate limited", event, payload)
+	}
+	wait(t, exceeded, "OnLimitExceeded")
+	// heartbeats are not limited
+	conn.send("phoenix", "heartbeat", map[string]any{})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("heartbeat: got %s %v", event, payload)
+	}
+}
+
+func TestMaxPayloadSize(t *testing.T) {
+	v, conn, page, exceeded := joinLimitedView(t, func(c *Config) {
+		c.MaxPayloadSize = 4096
+	})
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "inc", "value": map[string]any{"pad": strings.Repeat("x", 4096)}})
+	event, payload := conn.recv()
+	resp, _ := payload["response"].(map[string]any)
+	if event != "phx_reply" || payload["status"] != "error" || resp["reason"] != "payload_too_large" {
+		t.Fatalf("large event: got %s %v, want error reply", event, payload)
+	}
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "inc", "value": map[string]any{}})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("event: got %s %v", event, payload)
+	}
+	if v.Clicks != 1 {
+		t.Errorf("got %d clicks, want the large event dropped", v.Clicks)
+	}
+	if err := wait(t, exceeded, "OnLimitExceeded"); !errors.Is(err, ErrPayloadSize) {
+		t.Errorf("OnLimitExceeded got %v, want ErrPayloadSize", err)
+	}
+}
+
+func TestMaxFormFields(t *testing.T) {
+	_, conn, page, exceeded := joinLimitedView(t, func(c *Config) {
+		c.MaxFormFields = 2
+		c.LimitPolicy = DisconnectOverLimit
+	})
+	conn.send(page.topic, "event", map[string]any{"type": "form", "event": "save", "value": "a=1&b=2"})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("form: got %s %v", event, payload)
+	}
+	conn.send(page.topic, "event", map[string]any{"type": "form", "event": "save", "value": "a=1&b=2&c=3"})
+	conn.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, msg, err := conn.conn.ReadMessage(); err == nil {
+		t.Fatalf("form with too many fields: got %s, want disconnect", msg)
+	}
+	if err := wait(t, exceeded, "OnLimitExceeded"); !errors.Is(err, ErrFormFields) {
+		t.Errorf("OnLimitExceeded got %v, want ErrFormFields", err)
+	}
+}
+
+func TestDropOverLimit(t *testing.T) {
+	v, conn, page, exceeded := joinLimitedView(t, func(c *Config) {
+		c.EventRate = rate.Every(time.Hour)
+		c.EventBurst = 2
+		c.LimitPolicy = DropOverLimit
+	})
+	click := map[string]any{"type": "click", "event": "inc", "value": map[string]any{}}
+	conn.send(page.topic, "event", click)
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("event: got %s %v", event, payload)
+	}
+	conn.send(page.topic, "event", click)
+	if err := wait(t, exceeded, "OnLimitExceeded"); !errors.Is(err, ErrEventRate) {
+		t.Errorf("OnLimitExceeded got %v, want ErrEventRate", err)
+	}
+	// the event gets no reply, so the next message is the heartbeat's
+	conn.send("phoenix", "heartbeat", map[string]any{})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("heartbeat: got %s %v", event, payload)
+	}
+	if v.Clicks != 1 {
+		t.Errorf("got %d clicks, want the event over the rate dropped", v.Clicks)
+	}
+}
@@ -1,20 +1,26 @@
 package live
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 
+	"github.com/canopyclimate/golive/changeset"
 	"github.com/canopyclimate/golive/htmltmpl"
 	"github.com/canopyclimate/golive/internal/tmpl"
 	"github.com/canopyclimate/golive/live/internal/phx"
+	"golang.org/x/exp/maps"
 	"golang.org/x/time/rate"
 
 	"github.com/google/uuid"
@@ -36,6 +42,7 @@ type Config struct {
 	//  - Load your LiveView Client Javascript (e.g. <script defer type="text/javascript" src="/js/index.js"></script>) without this, your LiveView will not work.
 	//  - Pass the LayoutDot to the liveViewContainerTag (i.e. {{ liveViewContainerTag .LayoutDot }})
 	//  - Set the CSRF token in a meta tag (i.e. <meta name="csrf-token" content="{{ .LayoutDot.CSRFToken }}">)
+	// If nil, DefaultLayout is used. Views implementing Layouter may use their own layouts instead.
 	RenderLayout func(http.ResponseWriter, *http.Request, *LayoutDot) (any, *htmltmpl.Template)
 	// OnViewError is called when an error occurs during a View lifecycle method (e.g. HandleEvent, HandleInfo, etc)
 	// AND the view is connected to a socket (as opposed to the initial HTTP request). OnViewError may be nil
@@ -43,14 +50,117 @@ type Config struct {
 	// the javascript client will receive a "phx_error" message via the connected socket which in the case
 	// of `HandleEvent` and `HandleInfo` will result in the client attempting to re-join the View.  For `HandleParams`,
 	// the error will result in a page reload which will start the HTTP request lifecycle over again.
+	// Errors wrapping ErrNotFound or ErrForbidden, including while joining, instead have the client
+	// load the View's URL straight away, so that the page for the error is rendered; see RenderError.
+	// Panics in a View's methods, or while handling a client's message, are recovered and reported
+	// as a *PanicError holding the stack, also during the initial HTTP request; see PanicError.
+	// v is nil if no View is joined, e.g. when the client was not allowed to join one.
 	OnViewError func(ctx context.Context, v View, url *url.URL, err error)
+	// RenderError renders the page for an error returned by a View's Mount or HandleParams method,
+	// or while rendering it, during the initial HTTP render. The response has the status of the
+	// error (see HTTPStatus and ErrNotFound). If nil, the page holds just the status text, so
+	// that internal error details do not leak to clients.
+	RenderError func(http.ResponseWriter, *http.Request, *ErrorDot) (any, *htmltmpl.Template)
+	// SecretKey is the key used to sign the session and flash tokens handed to clients.
+	// It should be at least 32 random bytes and be shared by all servers of an application.
+	// If empty, a random key is generated per process, which means tokens do not survive
+	// restarts and are not valid across servers.
+	SecretKey []byte
+	// SessionMaxAge is how long the session embedded in the initial HTTP render may be used
+	// to join a View over a websocket. Older sessions are rejected and the client reloads the page.
+	// If zero, it defaults to two weeks.
+	SessionMaxAge time.Duration
+	// SessionData, if non-nil, returns data from the initial HTTP request (e.g. the current user's ID)
+	// that is passed to Mount as Params.Session, both during the HTTP render and when the View
+	// joins over a websocket. The data is signed, but not encrypted, and must be JSON-encodable.
+	// Note that after being carried over to the websocket, JSON numbers become float64s.
+	SessionData func(r *http.Request) map[string]any
+	// MailboxSize is the number of Infos sent with SendInfo that may be queued for a View
+	// before MailboxOverflow applies. If zero, it defaults to 64.
+	MailboxSize int
+	// MailboxOverflow determines what happens to an Info sent to a View whose mailbox is full.
+	// The zero value, DropNewest, discards the Info being sent. SendInfo never blocks.
+	MailboxOverflow OverflowPolicy
+	// FormDecoder decodes the fields of form events in Event.Decode.
+	// If nil, a decoder based on github.com/go-playground/form is used.
+	FormDecoder changeset.Decoder
+	// HeartbeatTimeout is how long a websocket may go without receiving anything from its client
+	// (a message, a heartbeat or a pong) before it is closed and its View terminated with
+	// ErrHeartbeatTimeout. This frees the resources of connections that died without closing.
+	// If zero, it defaults to 60s; the client sends a heartbeat every 30s.
+	HeartbeatTimeout time.Duration
+	// PingInterval is how often a websocket ping control frame is sent to the client, whose
+	// browser answers it with a pong. If zero, it defaults to half of HeartbeatTimeout.
+	// If negative, no pings are sent.
+	PingInterval time.Duration
+	// WriteTimeout is how long writing a message, or a batch of queued messages, to a websocket
+	// may take before the connection is closed. If zero, it defaults to 10s.
+	WriteTimeout time.Duration
+	// MaxMessageSize is the size in bytes of the largest message a client may send,
+	// including upload chunks (see UploadConfig.ChunkSize). Larger messages close the
+	// connection. If zero, it defaults to 1MiB.
+	MaxMessageSize int64
+	// AllowedOrigins lists the origins, besides the server's own host, that browsers may open
+	// websockets from, e.g. "https://example.com". An origin of the form "https://*.example.com"
+	// allows the subdomains of example.com, and "*" allows any origin. Requests from other origins
+	// are rejected with http.StatusForbidden, so that other sites cannot act as their visitors.
+	AllowedOrigins []string
+	// MaxConnections is the maximum number of websockets a WebsocketHandler serves at once.
+	// Further requests are rejected with http.StatusServiceUnavailable. If zero, there is no limit.
+	MaxConnections int
+	// MaxConnectionsPerClient is the maximum number of websockets a WebsocketHandler serves
+	// at once per client key (see ClientKey). Further requests are rejected with
+	// http.StatusTooManyRequests. If zero, there is no limit.
+	MaxConnectionsPerClient int
+	// JoinRate is the rate per second at which each client key (see ClientKey) may join Views,
+	// allowing bursts of up to JoinBurst joins (at least 1). Joins over the limit are rejected,
+	// and the client reloads the page after a while; websocket requests from a client out of
+	// joins are rejected with http.StatusTooManyRequests. If zero, joins are not limited.
+	JoinRate rate.Limit
+	// JoinBurst is the burst size of JoinRate.
+	JoinBurst int
+	// ClientKey returns the key a websocket request's client is admitted under for
+	// MaxConnectionsPerClient and JoinRate. If nil, it is the IP address of http.Request.RemoteAddr;
+	// behind a proxy, return the client's address as reported by the proxy instead.
+	ClientKey func(r *http.Request) string
+	// EventRate is the rate per second at which the client of a websocket may send messages,
+	// allowing bursts of up to EventBurst messages (at least 1). It counts events, but also
+	// joins, patches and the like; heartbeats, leaves and upload chunks, which are bounded by
+	// MaxMessageSize and UploadConfig, are not limited. If zero, messages are not limited.
+	EventRate rate.Limit
+	// EventBurst is the burst size of EventRate.
+	EventBurst int
+	// MaxPayloadSize is the size in bytes of the largest text message a client may send;
+	// larger messages are not decoded before LimitPolicy applies. Unlike MaxMessageSize, it
+	// does not close the connection by itself. If zero, only MaxMessageSize applies.
+	MaxPayloadSize int
+	// MaxFormFields is the maximum number of fields of a form event. If zero, there is no limit.
+	MaxFormFields int
+	// LimitPolicy determines what happens to a message exceeding EventRate, MaxPayloadSize or
+	// MaxFormFields. The zero value, ReplyOverLimit, discards it, replying with an error.
+	LimitPolicy LimitPolicy
+	// OnLimitExceeded, if non-nil, is called with the View and an error wrapping ErrEventRate,
+	// ErrPayloadSize or ErrFormFields whenever a client's message exceeds a limit, before
+	// LimitPolicy applies. The View is nil if the client has not joined one.
+	OnLimitExceeded func(ctx context.Context, v View, err error)
+	// WriteQueueSize is the number of messages that may be queued to be written to a websocket.
+	// Messages are written by a goroutine of their own, so that a View is not held up by a
+	// client reading slowly until its queue is full; then SlowConsumerPolicy applies.
+	// Diffs queued one after the other are merged as they are written, so a client
+	// falling behind receives fewer, larger diffs. If zero, it defaults to 256.
+	WriteQueueSize int
+	// SlowConsumerPolicy determines what happens when a websocket's write queue is full.
+	// The zero value, WaitForSlowConsumer, blocks the View for up to WriteTimeout before
+	// disconnecting the client with ErrSlowConsumer. See also WebsocketHandler.WriteQueueStats.
+	SlowConsumerPolicy SlowConsumerPolicy
 }
 
 type (
 	liveViewRequestContextKey struct{}
 	liveViewContainer         struct {
-		lv View
-		r  *http.Request
+		lv          View
+		r           *http.Request
+		liveSession string
 	}
 )
 
@@ -72,7 +182,26 @@ func (c *Config) viewForRequest(w http.ResponseWriter, r *http.Request, currentV
 
 func (c *Config) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A panicking View or Mux fails the request with an internal error page,
+		// rather than the whole process. Nothing is written before rendering ends.
+		// Panics of next are left to it.
+		var lv View
+		passed := false
+		defer func() {
+			if passed {
+				return
+			}
+			if p := recover(); p != nil {
+				err := newPanicError(p)
+				if c.OnViewError != nil {
+					c.OnViewError(r.Context(), lv, r.URL, err)
+				}
+				c.renderError(w, r, err)
+			}
+		}()
+
 		if c.ShouldHandleRequest != nil && !c.ShouldHandleRequest(r) {
+			passed = true
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -81,13 +210,14 @@ func (c *Config) Middleware(next http.Handler) http.Handler {
 		lv, code, r := c.viewForRequest(w, r, nil)
 
 		// If the inner router 500s, cease the middleware chain.
-		if code%100 == 5 {
+		if code/100 == 5 {
 			return
 		}
 
 		// If no view was found continue the chain without upgrading the request to a live one;
 		// the outer router will presumably serve this route, but we no longer care about it.
 		if lv == nil {
+			passed = true
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -105,18 +235,30 @@ func (c *Config) Middleware(next http.Handler) http.Handler {
 		// Run initial Lifecycle Mount => HandleParams => Render
 		// We never call HandleEvent or HandleInfo for HTTP requests
 		ctx := r.Context()
-		// add a faux socket for uploadConfigs
+		// add a faux, disconnected socket for uploadConfigs and the like
 		uploadConfigs := make(map[string]*UploadConfig)
-		ctx = withSocket(ctx, &socket{
+		comps := newComponents()
+		flash := c.popFlashCookie(w, r)
+		fs := &socket{
 			uploadConfigs: uploadConfigs,
-		})
+			components:    comps,
+			flash:         flash,
+			connInfo:      newConnInfo(r, nil),
+			route:         r,
+		}
+		ctx = withSocket(ctx, fs)
+
+		var sessData map[string]any
+		if c.SessionData != nil {
+			sessData = c.SessionData(r)
+		}
 
 		// if View implements Mounter interface then call Mount
 		m, ok := lv.(Mounter)
 		if ok {
-			err := m.Mount(ctx, Params{})
+			err := m.Mount(ctx, Params{Session: sessData})
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				c.renderError(w, r, err)
 				return
 			}
 		}
@@ -126,7 +268,7 @@ func (c *Config) Middleware(next http.Handler) http.Handler {
 		if ok {
 			err := hp.HandleParams(ctx, r.URL)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				c.renderError(w, r, err)
 				return
 			}
 		}
@@ -134,27 +276,51 @@ func (c *Config) Middleware(next http.Handler) http.Handler {
 		// Users may overwrite this in WriteLayout if they wish.
 		csrf := uuid.New().String()
 		meta := &Meta{
-			Uploads:   uploadConfigs,
-			CSRFToken: csrf,
+			Uploads:    uploadConfigs,
+			CSRFToken:  csrf,
+			Flash:      flash,
+			Streams:    fs.streamUpdates(),
+			components: &componentRender{ctx: ctx, components: comps},
 		}
 
 		lvd, lvt := lv.Render(ctx, meta)
 
+		// honor any PageTitle calls made while rendering
+		if fs.title != "" {
+			ptc.Title = fs.title
+		}
+
+		id := uuid.New().String() // TODO use nanoID or something shorter?
 		ldot := &LayoutDot{
-			LiveViewID:   uuid.New().String(), // TODO use nanoID or something shorter?
-			CSRFToken:    csrf,
-			PageTitle:    ptc,
+			LiveViewID: id,
+			CSRFToken:  csrf,
+			PageTitle:  ptc,
+			Flash:      flash,
+			session: &session{
+				ID:          id,
+				View:        fmt.Sprintf("%T", lv),
+				Layout:      layoutName(lv),
+				LiveSession: liveSessionName(r),
+				Data:        sessData,
+				Flash:       flash,
+				Expires:     time.Now().Add(c.sessionMaxAge()).Unix(),
+			},
+			signer:       c.signer(),
 			viewTemplate: lvt,
 			viewDot:      lvd,
 		}
 
-		// TODO: Fallback to a hardcoded base layout if WriteLayout isn't set.
-		ld, lt := c.RenderLayout(w, r, ldot)
-		err := lt.Execute(w, ld)
+		layouts := c.layouts(lv)
+		ldot.w, ldot.r, ldot.inner = w, r, layouts[1:]
+		ld, lt := layouts[0](w, r, ldot)
+		// render to a buffer, so that errors are not appended to a partial page
+		var buf bytes.Buffer
+		err := lt.Execute(&buf, ld)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			c.renderError(w, r, err)
 			return
 		}
+		w.Write(buf.Bytes())
 	})
 }
 
@@ -164,6 +330,17 @@ type Meta struct {
 	CSRFToken string
 	URL       url.URL
 	Uploads   map[string]*UploadConfig
+	// Connected reports whether the View is connected to a websocket; see Connected.
+	Connected bool
+	// Flash holds the current flash messages by kind; see PutFlash.
+	Flash map[string]string
+	// Myself is the ID of the Component being rendered, for use as a phx-target.
+	// It is zero when rendering a View.
+	Myself int
+	// Streams holds the updates to the View's streams by name; see Stream.
+	Streams map[string]*StreamUpdate
+
+	components *componentRender
 }
 
 // Params is the data passed to a View's Mount method.
@@ -171,6 +348,10 @@ type Params struct {
 	CSRFToken string
 	Mounts    int
 	Data      map[string]any
+	// Connected reports whether the View is connected to a websocket; see Connected.
+	Connected bool
+	// Session is the data returned by Config.SessionData for the initial HTTP request.
+	Session map[string]any
 }
 
 // View is a live view which requires a Render method in order to be
@@ -212,22 +393,35 @@ type PageTitleConfigurer interface {
 	PageTitleConfig() PageTitleConfig
 }
 
-// A Router creates a Handler given a URL.
-// TODO: rethink this with a better muxer, maybe the standard library muxer.
-type Router = func(*url.URL) View
-
 // NewWebsocketHandler returns a http.Handler that handles upgrading
 // HTTP requests to WebSockets and handling message routing.
 func NewWebsocketHandler(c Config) *WebsocketHandler {
-	return &WebsocketHandler{config: c}
+	x := &WebsocketHandler{config: c, queues: newWriteQueues()}
+	x.admission = newAdmission(&x.config)
+	return x
 }
 
 // WebsocketHandler handles Websocket requests and message routing.
 type WebsocketHandler struct {
-	config Config
+	config    Config
+	admission *admission
+	queues    *writeQueues
 }
 
 func (x *WebsocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Reject requests before upgrading them, so that they get a proper HTTP status.
+	if !x.config.checkOrigin(r) {
+		http.Error(w, "websocket origin not allowed", http.StatusForbidden)
+		return
+	}
+	key := x.config.clientKey(r)
+	release, status := x.admission.admit(key)
+	if release == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer release()
+
 	// TODO: route maps
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -241,79 +435,230 @@ func (x *WebsocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		config:         x.config,
 		readerr:        make(chan error),
 		msg:            make(chan *phx.Msg),
-		info:           make(chan *Info),
+		info:           make(chan mail, x.config.mailboxSize()),
+		async:          make(chan asyncDone),
 		upload:         make(chan *phx.UploadMsg),
-		nav:            make(chan *phx.Nav),
+		out:            make(chan []byte, x.config.writeQueueSize()),
+		writerDone:     make(chan struct{}),
+		queues:         x.queues,
 		uploadConfigs:  make(map[string]*UploadConfig),
+		components:     newComponents(),
+		flash:          make(map[string]string),
+		connected:      true,
+		done:           make(chan struct{}),
 		errTokenBucket: rate.NewLimiter(rate.Limit(1/15.0), 3), // at most one event per 15s on average, but 3 initial retries free
+		admission:      x.admission,
+		clientKey:      key,
 	}
+	if x.config.EventRate > 0 {
+		s.eventLimiter = rate.NewLimiter(x.config.EventRate, max(x.config.EventBurst, 1))
+	}
+	defer x.queues.track(s)()
 	go s.read()
+	go s.writeLoop()
 	s.serve(r.Context())
 }
 
 func (s *socket) read() {
+	s.conn.SetReadLimit(s.config.maxMessageSize())
+	s.conn.SetPongHandler(func(string) error { return s.keepAlive() })
 	for {
+		err := s.keepAlive()
+		if err != nil {
+			s.readErr(fmt.Errorf("websocket read: %v", err))
+			return
+		}
 		msgType, msg, err := s.conn.ReadMessage()
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			s.readErr(ErrHeartbeatTimeout)
+			return
+		}
 		if err != nil {
-			s.readerr <- fmt.Errorf("websocket read: %v", err)
+			s.readErr(fmt.Errorf("websocket read: %v", err))
 			return
 		}
 		if msgType == websocket.BinaryMessage {
 			um := &phx.UploadMsg{}
 			err := um.UnmarshalBinary(msg)
 			if err != nil {
-				s.readerr <- fmt.Errorf("unmarshaling upload message: %v", err)
+				s.readErr(fmt.Errorf("unmarshaling upload message: %v", err))
+				return
+			}
+			select {
+			case s.upload <- um:
+			case <-s.done:
 				return
 			}
-			s.upload <- um
 			continue
 		}
 
-		pm, err := phx.Parse(msg)
+		// Don't bother decoding the payload of a message that is over the limit.
+		parse := phx.Parse
+		if limit := s.config.MaxPayloadSize; limit > 0 && len(msg) > limit {
+			parse = phx.ParseHeader
+		}
+		pm, err := parse(msg)
 		if err != nil {
-			s.readerr <- fmt.Errorf("malformed phx message: %v", err)
+			s.readErr(fmt.Errorf("malformed phx message: %v", err))
+			return
+		}
+		select {
+		case s.msg <- pm:
+		case <-s.done:
 			return
 		}
-		s.msg <- pm
+	}
+}
+
+// readErr hands err to serve, unless it is already done.
+func (s *socket) readErr(err error) {
+	select {
+	case s.readerr <- err:
+	case <-s.done:
 	}
 }
 
 func (s *socket) serve(ctx context.Context) {
 	ctx = withSocket(ctx, s)
 
+	// However serve returns, tear down the View.
+	reason := ErrDisconnected
+	defer func() {
+		close(s.done)
+		err := protect(func() error { return s.terminate(reason) })
+		if err != nil && s.config.OnViewError != nil {
+			s.config.OnViewError(s.viewCtx, s.view, &s.url, err)
+		}
+	}()
+
 	for {
+		// Once a View has joined, its methods are called with its own context.
+		vctx := ctx
+		if s.viewCtx != nil {
+			vctx = s.viewCtx
+		}
 		var r []byte
 		res := [][]byte{}
 		var err error
 		select {
-		case info := <-s.info:
-			r, err = s.handleInfo(ctx, info)
-			if err == nil {
+		case m := <-s.info:
+			if m.view.Err() != nil {
+				// sent to a View that has since terminated
+				continue
+			}
+			err = protect(func() (err error) {
+				if rc, ok := s.view.(RenderCoalescer); ok {
+					r, err = s.handleInfos(vctx, rc, m.info)
+				} else {
+					r, err = s.handleInfo(vctx, m.info)
+				}
+				return err
+			})
+			if err == nil && r != nil {
 				res = append(res, r)
 			}
-		case pm := <-s.msg:
-			r, err = s.dispatch(ctx, pm)
+		case <-s.frame.C():
+			// a render postponed by the View's MaxFrameRate is due
+			s.frame.timer = nil
+			rc, ok := s.view.(RenderCoalescer)
+			if !ok {
+				continue
+			}
+			err = protect(func() (err error) {
+				r, err = s.renderFrame(vctx, rc)
+				return err
+			})
+			if err == nil && r != nil {
+				res = append(res, r)
+			}
+		case d := <-s.async:
+			if d.task.Err() != nil {
+				// cancelled since it finished
+				continue
+			}
+			err = protect(func() (err error) {
+				r, err = s.handleAsync(vctx, d)
+				return err
+			})
 			if err == nil {
 				res = append(res, r)
 			}
-		case um := <-s.upload:
-			res, err = s.handleUpload(ctx, um)
-		case nm := <-s.nav:
-			r, err = nm.JSON()
+		case pm := <-s.msg:
+			if lerr := s.checkLimits(pm); lerr != nil {
+				err = protect(func() (err error) {
+					r, err = s.limitExceeded(vctx, pm, lerr)
+					return err
+				})
+				if errors.Is(err, ErrDisconnected) {
+					reason = err
+					return
+				}
+				if err == nil && r == nil {
+					// dropped without a reply
+					continue
+				}
+			} else {
+				// Handling a message must not crash the process, whether the View
+				// panics or the client sent a payload of unexpected shape.
+				err = protect(func() (err error) {
+					r, err = s.dispatch(vctx, pm)
+					return err
+				})
+			}
 			if err == nil {
 				res = append(res, r)
 			}
+		case um := <-s.upload:
+			err = protect(func() (err error) {
+				res, err = s.handleUpload(vctx, um)
+				return err
+			})
+		case <-s.writerDone:
+			reason = fmt.Errorf("%w: %v", ErrDisconnected, s.writeErr)
+			return
 		case err := <-s.readerr:
 			// String matching. Much sadness.
-			if !strings.Contains(err.Error(), "websocket: close") {
+			if !strings.Contains(err.Error(), "websocket: close") && !errors.Is(err, ErrHeartbeatTimeout) {
 				log.Printf("websocket read failed: %v", err)
 			}
+			reason = fmt.Errorf("%w: %w", ErrDisconnected, err)
+			return
+		case <-ctx.Done():
+			reason = fmt.Errorf("%w: %v", ErrDisconnected, context.Cause(ctx))
 			return
 		}
-		if err != nil {
+		if s.viewCtx != nil {
+			vctx = s.viewCtx
+		}
+		var jre *joinRejectedError
+		if errors.As(err, &jre) {
+			if s.config.OnViewError != nil {
+				s.config.OnViewError(vctx, s.view, &s.url, err)
+			}
+			// let the client know to reload the page
+			if jre.redirect != "" {
+				r, err = phx.NewJoinRedirect(jre.msg, jre.redirect).JSON()
+			} else {
+				r, err = phx.NewErrorReply(jre.msg, jre.reason).JSON()
+			}
+			if err != nil {
+				panic(err) // theoretically should never happen
+			}
+			res = append(res, r)
+		} else if status := HTTPStatus(err); err != nil && status != http.StatusInternalServerError {
+			if s.config.OnViewError != nil {
+				s.config.OnViewError(vctx, s.view, &s.url, err)
+			}
+			// load the page, rendering the error page for the status
+			r, err = phx.NewNav(s.id, "redirect", phx.NavPayload{To: s.url.String()}).JSON()
+			if err != nil {
+				panic(err) // theoretically should never happen
+			}
+			res = append(res, r)
+		} else if err != nil {
 			// call configured error handler if set
 			if s.config.OnViewError != nil {
-				s.config.OnViewError(ctx, s.view, &s.url, err)
+				s.config.OnViewError(vctx, s.view, &s.url, err)
 			}
 			// Rate limit error responses. This prevents retries from overwhelming the server.
 			// It would be better for the client to have some kind of graceful backoff,
@@ -327,8 +672,9 @@ func (s *socket) serve(ctx context.Context) {
 			res = append(res, b)
 		}
 		for _, m := range res {
-			err = s.conn.WriteMessage(websocket.TextMessage, m)
+			err = s.enqueue(m)
 			if err != nil {
+				reason = fmt.Errorf("%w: %w", ErrDisconnected, err)
 				return
 			}
 		}
@@ -341,43 +687,84 @@ type socket struct {
 	conn              *websocket.Conn
 	config            Config
 	view              View
-	id                string // aka join topic
-	joinRef           string // initial join ref
-	msgRef            string // initial message ref
+	viewCtx           context.Context         // context of the joined View
+	cancelView        context.CancelCauseFunc // cancels viewCtx; nil once the View has terminated
+	done              chan struct{}           // closed once the socket stops serving
+	tree              *tmpl.Tree              // last tree sent to the client
+	id                string                  // aka join topic
+	joinRef           string                  // initial join ref
+	msgRef            string                  // initial message ref
 	msg               chan *phx.Msg
-	info              chan *Info
+	info              chan mail // the View's mailbox
+	async             chan asyncDone
+	asyncs            map[string]context.CancelFunc // running async work by name
 	upload            chan *phx.UploadMsg
-	nav               chan *phx.Nav
 	events            []*Event
+	reply             map[string]any // reply to the event being handled
 	readerr           chan error
 	title             string
 	url               url.URL
 	redirect          string
 	csrfToken         string
 	uploadConfigs     map[string]*UploadConfig
+	components        *components
+	streams           map[string]*stream
+	flash             map[string]string
+	connInfo          *ConnInfo
+	connected         bool // false for the faux socket used by Config.Middleware
 	activeUploadRef   string
 	activeUploadTopic string
 	errTokenBucket    *rate.Limiter
+	route             *http.Request // the request routed to the View, for PathValue
+	admission         *admission    // of the WebsocketHandler serving this socket
+	clientKey         string        // the key the client was admitted under
+	eventLimiter      *rate.Limiter // limits the client's messages, if Config.EventRate is set
+	out               chan []byte   // messages queued for writeLoop
+	writerDone        chan struct{} // closed when writeLoop stops
+	writeErr          error         // why writeLoop stopped, once writerDone is closed
+	queues            *writeQueues  // of the WebsocketHandler serving this socket
+	frame             frameTimer    // renders of a RenderCoalescer postponed for its frame rate
 }
 
 func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 	event := msg.Event
 	switch event {
+	case "event", "live_patch", "allow_upload", "progress", "cids_will_destroy", "cids_destroyed":
+		// only the View that joined, and has not terminated since, handles these
+		if !s.joined(msg.Topic) {
+			return nil, fmt.Errorf("%s sent to %s, which no View is joined on", event, msg.Topic)
+		}
+	}
+	switch event {
 	case "phx_join":
 		// check if topic starts with "lv:" or "lvu:"
 		// "lv:" is a liveview
 		// "lvu:" is a liveview upload
 		switch {
 		case strings.HasPrefix(msg.Topic, "lv:"):
+			if !s.admission.allowJoin(s.clientKey) {
+				return nil, rejectJoin(msg, joinRateLimited, fmt.Errorf("client %s exceeded the join rate", s.clientKey))
+			}
+			// joining replaces the previous View, if any
+			err := s.terminate(ErrViewLeft)
+			s.view, s.route = nil, nil
+			if err != nil {
+				return nil, err
+			}
+			// verify the session rendered into the initial HTTP response
+			sess, err := s.config.verifySession(msg)
+			if err != nil {
+				return nil, err
+			}
 
 			// first we need to read the msg to see what route we're on
 			// on join the message payload should include a "url" key or
 			// a "redirect" key
-			urlStr := ""
+			urlStr, isRedirect := "", false
 			if u, ok := msg.Payload["url"].(string); ok {
 				urlStr = u
 			} else if u, ok := msg.Payload["redirect"].(string); ok {
-				urlStr = u
+				urlStr, isRedirect = u, true
 			}
 			if urlStr == "" {
 				return nil, fmt.Errorf("no url or redirect found in payload")
@@ -387,21 +774,25 @@ func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 			if err != nil {
 				return nil, fmt.Errorf("could not parse url: %v", err)
 			}
-			// look up View by url path
-			// TODO: we could thread the http request that initiated this request
-			// all the way through to here and re-use it except for the newly
-			// requested URL, which might be useful for routing if it contains
-			// headers or the like(?)
-			r := s.req.Clone(s.req.Context()) // todo: background context?
-			r.URL = url
-			var code int
-			s.view, code, _ = s.config.viewForRequest(nil, r, nil)
-			if code%100 == 5 {
-				return nil, fmt.Errorf("error finding view for url: %v", err)
+			// look up View by url; it is only installed once the join is authorized
+			view, route, err := s.viewForURL(url, nil)
+			if err != nil {
+				return nil, err
+			}
+			if view == nil {
+				// The client followed a live redirect to a URL that is not a View,
+				// so have it load the page instead.
+				return phx.NewJoinRedirect(*msg, url.String()).JSON()
+			}
+			// Following a live redirect, the client joins the new View with the
+			// session of the View it was rendered with, which is only allowed
+			// within a live session; see SetLiveSession. Otherwise they must match.
+			if v := fmt.Sprintf("%T", view); !isRedirect && v != sess.View {
+				return nil, rejectJoin(msg, joinUnauthorized, fmt.Errorf("session for view %s used to join %s", sess.View, v))
 			}
-			if s.view == nil {
-				// TODO: something better here!
-				return nil, fmt.Errorf("404")
+			// The page must be reloaded to render a View in another live session or layout.
+			if isRedirect && (sess.LiveSession == "" || liveSessionName(route) != sess.LiveSession || layoutName(view) != sess.Layout) {
+				return phx.NewJoinRedirect(*msg, url.String()).JSON()
 			}
 
 			// get data from params
@@ -410,47 +801,55 @@ func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 				return nil, fmt.Errorf("params not found in payload")
 			}
 			// pull out known params
+			csrf, _ := rawParams["_csrf_token"].(string)
+			mounts, _ := rawParams["_mounts"].(float64)
 			params := Params{
-				CSRFToken: rawParams["_csrf_token"].(string),
-				Mounts:    int(rawParams["_mounts"].(float64)),
+				CSRFToken: csrf,
+				Mounts:    int(mounts),
 				Data:      rawParams,
+				Session:   sess.Data,
+				Connected: true,
+			}
+			if params.CSRFToken != sess.CSRF {
+				return nil, rejectJoin(msg, joinUnauthorized, fmt.Errorf("CSRF token does not match session"))
 			}
 
+			s.view = view
+			s.route = route
 			s.id = msg.Topic
 			s.joinRef = msg.JoinRef
 			s.msgRef = msg.MsgRef
 			s.url = *url
 			s.csrfToken = params.CSRFToken
-
-			// Join is the initalize event and the only time we call Mount on the view.
-			// Only call Mount if the view implements Mounter
-			m, ok := s.view.(Mounter)
-			if ok {
-				err := m.Mount(ctx, params)
-				if err != nil {
-					return nil, err
-				}
+			s.components = newComponents()
+			s.streams = nil
+			s.asyncs = nil
+			s.connInfo = newConnInfo(s.req, rawParams)
+
+			vctx, cancel := context.WithCancelCause(withSocket(s.req.Context(), s))
+			s.viewCtx = context.WithValue(vctx, viewContextKey{}, vctx)
+			s.cancelView = cancel
+			ctx = s.viewCtx
+
+			// Flash messages arrive in the session from the HTTP render,
+			// or as a separate token following a live redirect.
+			s.flash = make(map[string]string)
+			if !isRedirect {
+				maps.Copy(s.flash, sess.Flash)
 			}
-			// Also call HandleParams during join to give the LiveView a chance
-			// to update its state based on the URL params
-			hp, ok := s.view.(ParamsHandler)
-			if ok {
-				err := hp.HandleParams(ctx, url)
-				if err != nil {
-					return nil, err
-				}
+			if token, ok := msg.Payload["flash"].(string); ok {
+				maps.Copy(s.flash, s.config.verifyFlash(token))
 			}
 
-			t, err := s.renderToTree(ctx)
+			rendered, err := s.mount(ctx, params, url)
 			if err != nil {
-				return nil, err
-			}
-			json, err := t.JSON()
-			if err != nil {
-				return nil, err
+				return nil, redirectJoin(msg, url.String(), err)
 			}
-			return phx.NewRendered(*msg, json).JSON()
+			return phx.NewRendered(*msg, rendered).JSON()
 		case strings.HasPrefix(msg.Topic, "lvu:"):
+			if s.cancelView == nil {
+				return nil, fmt.Errorf("upload %s joined without a View", msg.Topic)
+			}
 			// set active upload topic
 			s.activeUploadTopic = msg.Topic
 			// basically send back an ack
@@ -459,73 +858,48 @@ func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 			return nil, fmt.Errorf("unknown join topic: %q", msg.Topic)
 		}
 	case "heartbeat":
-		// TODO - set a timer that gets reset on every heartbeat
-		// if the timer expires, we should Close the live view
-		// and try to send a message to the client saying we are closing
-		// the connection
+		// reading it has already pushed back the heartbeat timeout
 		return phx.NewHeartbeat(msg.MsgRef).JSON()
 	case "event":
+		s.reply = nil
 		// all events payloads have a few shared keys
-		et := msg.Payload["type"].(string)
-		ee := msg.Payload["event"].(string)
-		// cid := phxMsg.Payload["cid"] // component ID (not used yet)
+		et, ok := msg.Payload["type"].(string)
+		if !ok {
+			return nil, fmt.Errorf("event type not found in payload")
+		}
+		ee, ok := msg.Payload["event"].(string)
+		if !ok {
+			return nil, fmt.Errorf("event name not found in payload")
+		}
+		eh, err := s.eventHandler(msg.Payload)
+		if err != nil {
+			return nil, err
+		}
 
-		var t *tmpl.Tree
 		switch et {
 		case "click", "keyup", "keydown", "blur", "focus", "hook":
-			// payload should be map[string]any
-			v := msg.Payload["value"].(map[string]any)
-			// convert the value map to a url.Values
-			vals := url.Values{}
-			for k, v := range v {
-				// Convert v to strings
-				// TODO this should work for numbers, bools, strings
-				// but it doesn't work for arrays or objects
-				// TBH - I am not sure we'll ever get those types here
-				vals.Add(k, fmt.Sprint(v))
+			e, err := s.newEvent(msg)
+			if err != nil {
+				return nil, err
 			}
 			// check if the click is a lv:clear-flash event
 			// which does not invoke HandleEvent but should
-			// set the flash value to "" and send a responseDiff
-			if event == "lv:clear-flash" {
-				flashKey := vals.Get("key")
-				// TODO clear flash
-				// s.handler.ClearFlash(flashKey)
-				log.Printf("clear flash event: %s", flashKey)
+			// clear the flash value and send a responseDiff
+			if ee == "lv:clear-flash" {
+				delete(s.flash, e.Data.Get("key"))
 			} else {
-				eh, ok := s.view.(EventHandler)
-				if !ok {
-					return nil, fmt.Errorf("view %T does not implement EventHandler", s.view)
-				}
-				err := eh.HandleEvent(ctx, &Event{Type: ee, Data: vals})
+				err := eh.HandleEvent(ctx, e)
 				if err != nil {
 					return nil, err
 				}
 			}
 		case "form":
-			vals, err := url.ParseQuery(msg.Payload["value"].(string))
+			e, err := s.newFormEvent(msg)
 			if err != nil {
 				return nil, err
 			}
-
-			// handle uploads before calling HandleEvent
-			if uploads, ok := msg.Payload["uploads"].(map[string]any); ok && len(uploads) != 0 {
-				// get _target from form data
-				uc_target := vals.Get("_target")
-				// get the upload config from the uploadConfigs map
-				uc := s.uploadConfigs[uc_target]
-				// found the upload config & uploads reference the upload config
-				if uc != nil && uc.Ref != "" && uploads[uc.Ref] != nil {
-					uc.AddEntries(uploads[uc.Ref].([]any))
-				}
-			}
-
-			// call the view's HandleEvent method if it implements EventHandler
-			eh, ok := s.view.(EventHandler)
-			if !ok {
-				return nil, fmt.Errorf("view %T does not implement EventHandler", s.view)
-			}
-			err = eh.HandleEvent(ctx, &Event{Type: ee, Data: vals})
+			// call the target's HandleEvent method
+			err = eh.HandleEvent(ctx, e)
 			if err != nil {
 				return nil, err
 			}
@@ -535,33 +909,39 @@ func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 		}
 		// check if we have a redirect
 		if s.redirect != "" {
-			return phx.NewRedirect(*msg, s.redirect).JSON()
+			flash, err := s.config.flashToken(s.flash)
+			if err != nil {
+				return nil, err
+			}
+			return phx.NewRedirect(*msg, s.redirect, flash).JSON()
 		}
 
-		// Now re-render the Tree
-		t, err := s.renderToTree(ctx)
-		if err != nil {
-			return nil, err
-		}
-		// TODO diff the Tree / Context and send only the changes
-		// for now, we send it all back...
-		// Note, we return a "diff" instead of a "rendered" response
-		diff, err := t.JSON()
+		// Now re-render the Tree and send only the changes
+		diff, err := s.renderDiff(ctx)
 		if err != nil {
 			return nil, err
 		}
 		return phx.NewReplyDiff(*msg, diff).JSON()
 	case "live_patch":
-		r := s.req.Clone(s.req.Context()) // todo: background context?
-		v, code, _ := s.config.viewForRequest(nil, r, s.view)
-		s.view = v // update the view
-		if code%100 == 5 {
-			return nil, fmt.Errorf("status code 500 patching LiveView in %v", r.URL)
+		urlStr, ok := msg.Payload["url"].(string)
+		if !ok {
+			return nil, fmt.Errorf("no url found in payload")
 		}
-		url, err := url.Parse(msg.Payload["url"].(string))
+		url, err := url.Parse(urlStr)
 		if err != nil {
 			return nil, err
 		}
+		// Patching only changes the params of the current View. If the URL
+		// is routed elsewhere, the client live redirects to it instead.
+		v, route, err := s.viewForURL(url, s.view)
+		if err != nil {
+			return nil, err
+		}
+		if !sameView(v, s.view) {
+			return phx.NewLinkRedirectReply(*msg).JSON()
+		}
+		s.url = *url
+		s.route = route
 		hp, ok := s.view.(ParamsHandler)
 		if ok {
 			err := hp.HandleParams(ctx, url)
@@ -569,42 +949,55 @@ func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 				return nil, err
 			}
 		}
-		// Now re-render the Tree
-		lt, err := s.renderToTree(ctx)
+		// Now re-render the Tree and send only the changes
+		diff, err := s.renderDiff(ctx)
 		if err != nil {
 			return nil, err
 		}
-		// TODO diff the Tree / Context and send only the changes
-		// for now, we send it all back...
-		// Note, we return a "diff" instead of a "rendered" response
-		diff, err := lt.JSON()
+		return phx.NewReplyDiff(*msg, diff).JSON()
+	case "phx_leave":
+		// ignore leaving a View that has already been replaced
+		if msg.Topic != s.id || msg.JoinRef != s.joinRef {
+			return phx.NewEmptyReply(*msg).JSON()
+		}
+		err := s.terminate(ErrViewLeft)
 		if err != nil {
 			return nil, err
 		}
-		return phx.NewReplyDiff(*msg, diff).JSON()
-	case "phx_leave":
-		if s.view != nil {
-			// check if the view implements the Closer interface
-			// and call Close() if it does. it is not an error if
-			// the view does not implement the Closer interface.
-			c, ok := s.view.(io.Closer)
-			if ok {
-				err := c.Close()
-				return nil, err
+		return phx.NewEmptyReply(*msg).JSON()
+	case "cids_will_destroy":
+		// The client is about to remove these components from the DOM.
+		// Nothing to do until it confirms with "cids_destroyed".
+		return phx.NewEmptyReply(*msg).JSON()
+	case "cids_destroyed":
+		raw, _ := msg.Payload["cids"].([]any)
+		cids := make([]int, 0, len(raw))
+		for _, c := range raw {
+			cid, ok := c.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid cid: %v", c)
 			}
+			s.components.delete(int(cid))
+			cids = append(cids, int(cid))
 		}
-		return nil, nil
+		return phx.NewCIDsReply(*msg, cids).JSON()
 	case "allow_upload":
 		// re-render tree
-		lt, err := s.renderToTree(ctx)
+		diffJson, err := s.renderDiff(ctx)
 		if err != nil {
 			return nil, err
 		}
 
 		// get upload ref and entries from payload
-		ref := msg.Payload["ref"].(string)
+		ref, ok := msg.Payload["ref"].(string)
+		if !ok {
+			return nil, fmt.Errorf("upload ref not found in payload")
+		}
+		entries, ok := msg.Payload["entries"].([]any)
+		if !ok {
+			return nil, fmt.Errorf("upload entries not found in payload")
+		}
 		s.activeUploadRef = ref
-		entries := msg.Payload["entries"].([]any)
 
 		// get upload config from uploadConfigs map
 		var uc *UploadConfig
@@ -624,18 +1017,18 @@ func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 		entriesMap := make(map[string]any)
 		entriesMap[ref] = ref
 		for _, entry := range entries {
-			entriesMap[entry.(map[string]any)["ref"].(string)] = entry
+			e, _ := entry.(map[string]any)
+			entryRef, ok := e["ref"].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid upload entry: %v", entry)
+			}
+			entriesMap[entryRef] = entry
 		}
 		entriesJson, err := json.Marshal(entriesMap)
 		if err != nil {
 			return nil, err
 		}
 
-		// build the diff component JSON
-		diffJson, err := lt.JSON()
-		if err != nil {
-			return nil, err
-		}
 		configJson, err := json.Marshal(constraints)
 		if err != nil {
 			return nil, err
@@ -643,9 +1036,19 @@ func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 
 		return phx.NewUploadReplyDiff(*msg, diffJson, configJson, entriesJson).JSON()
 	case "progress":
-		ref := msg.Payload["ref"].(string)
-		entryRef := msg.Payload["entry_ref"].(string)
-		progress := int(msg.Payload["progress"].(float64))
+		ref, ok := msg.Payload["ref"].(string)
+		if !ok {
+			return nil, fmt.Errorf("upload ref not found in payload")
+		}
+		entryRef, ok := msg.Payload["entry_ref"].(string)
+		if !ok {
+			return nil, fmt.Errorf("upload entry ref not found in payload")
+		}
+		p, ok := msg.Payload["progress"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("upload progress not found in payload")
+		}
+		progress := int(p)
 
 		// get the upload config from the uploadConfigs map
 		var uc *UploadConfig
@@ -668,21 +1071,93 @@ func (s *socket) dispatch(ctx context.Context, msg *phx.Msg) ([]byte, error) {
 		}
 
 		// re-render tree
-		// Now re-render the Tree
-		lt, err := s.renderToTree(ctx)
+		// Now re-render the Tree and send only the changes
+		diff, err := s.renderDiff(ctx)
 		if err != nil {
 			return nil, err
 		}
-		// TODO diff the Tree / Context and send only the changes
-		// for now, we send it all back...
-		// Note, we return a "diff" instead of a "rendered" response
-		diff, err := lt.JSON()
+		return phx.NewReplyDiff(*msg, diff).JSON()
+	}
+	return nil, fmt.Errorf("unknown event: %s", event)
+}
+
+// mount mounts the joining View and renders it in full. If that fails, or panics,
+// the View is torn down, so that it handles nothing else; see ErrJoinFailed.
+func (s *socket) mount(ctx context.Context, params Params, url *url.URL) (rendered []byte, err error) {
+	defer func() {
+		if rendered == nil {
+			s.discardView(err)
+		}
+	}()
+	// Join is the initalize event and the only time we call Mount on the view.
+	// Only call Mount if the view implements Mounter
+	m, ok := s.view.(Mounter)
+	if ok {
+		err := m.Mount(ctx, params)
 		if err != nil {
 			return nil, err
 		}
-		return phx.NewReplyDiff(*msg, diff).JSON()
 	}
-	return nil, fmt.Errorf("unknown event: %s", event)
+	// Also call HandleParams during join to give the LiveView a chance
+	// to update its state based on the URL params
+	hp, ok := s.view.(ParamsHandler)
+	if ok {
+		err := hp.HandleParams(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Joining always sends the full tree, statics included.
+	s.tree = nil
+	return s.renderDiff(ctx)
+}
+
+// joined reports whether a View is joined on topic, and has not terminated since.
+func (s *socket) joined(topic string) bool {
+	return s.cancelView != nil && topic == s.id
+}
+
+// viewForURL routes u through the Config's Mux as if it had been requested with the
+// websocket's request, returning its View, or nil if it is not routed to one,
+// and the request as routed.
+// Routes using MakeView reuse current if it is of the right type.
+func (s *socket) viewForURL(u *url.URL, current View) (View, *http.Request, error) {
+	r := s.req.Clone(s.req.Context())
+	r.URL = u
+	r.RequestURI = u.RequestURI()
+	v, code, r := s.config.viewForRequest(nil, r, current)
+	if code/100 == 5 {
+		return nil, nil, fmt.Errorf("status code %d routing LiveView for %v", code, u)
+	}
+	return v, r, nil
+}
+
+// sameView reports whether v and current are the same type of View,
+// so navigating from current to v is a patch rather than a redirect.
+func sameView(v, current View) bool {
+	return v != nil && reflect.TypeOf(v) == reflect.TypeOf(current)
+}
+
+// eventHandler returns the EventHandler targeted by an event payload:
+// the Component identified by its "cid" key if present, otherwise the View.
+func (s *socket) eventHandler(payload map[string]any) (EventHandler, error) {
+	cid, ok := payload["cid"].(float64)
+	if !ok {
+		eh, ok := s.view.(EventHandler)
+		if !ok {
+			return nil, fmt.Errorf("view %T does not implement EventHandler", s.view)
+		}
+		return eh, nil
+	}
+	lc, ok := s.components.byCID[int(cid)]
+	if !ok {
+		return nil, fmt.Errorf("no component found for cid %v", cid)
+	}
+	eh, ok := lc.c.(EventHandler)
+	if !ok {
+		return nil, fmt.Errorf("component %T does not implement EventHandler", lc.c)
+	}
+	return eh, nil
 }
 
 // handleInfo receives internal messages then runs: HandleInfo => Render
@@ -696,20 +1171,22 @@ func (s *socket) handleInfo(ctx context.Context, info *Info) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	t, err := s.renderToTree(ctx)
+	diff, err := s.renderDiff(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("rendering error: %v", err)
 	}
-	diff, err := t.JSON()
-	if err != nil {
-		return nil, err
-	}
 	return phx.NewDiff(nil, s.id, diff).JSON()
 }
 
 func (s *socket) handleUpload(ctx context.Context, up *phx.UploadMsg) (res [][]byte, err error) {
+	if s.cancelView == nil {
+		return res, fmt.Errorf("upload to %s without a View", up.Topic)
+	}
 	// get ref from topic
-	ref := strings.Split(up.Topic, ":")[1]
+	_, ref, ok := strings.Cut(up.Topic, ":")
+	if !ok {
+		return res, fmt.Errorf("invalid upload topic: %q", up.Topic)
+	}
 
 	// get uploadConfig for activeUploadRef
 	var uc *UploadConfig
@@ -769,22 +1246,52 @@ func (s *socket) handleUpload(ctx context.Context, up *phx.UploadMsg) (res [][]b
 	return res, nil
 }
 
-var upgrader = &websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+// upgrader upgrades websocket requests. Their origin has been checked by WebsocketHandler.
+var upgrader = &websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
 
 func (s *socket) renderToTree(ctx context.Context) (*tmpl.Tree, error) {
+	cr := &componentRender{
+		ctx:        ctx,
+		components: s.components,
+		trees:      make(map[int]*tmpl.Tree),
+	}
 	meta := &Meta{
-		URL:       s.url,
-		CSRFToken: s.csrfToken,
-		Uploads:   s.uploadConfigs,
+		URL:        s.url,
+		CSRFToken:  s.csrfToken,
+		Uploads:    s.uploadConfigs,
+		Flash:      s.flash,
+		Connected:  true,
+		Streams:    s.streamUpdates(),
+		components: cr,
 	}
 	dot, t := s.view.Render(ctx, meta)
 
 	tree, err := t.ExecuteTree(dot)
+	if err != nil {
+		return nil, err
+	}
+	resolveComponents(tree)
+	if len(cr.trees) > 0 {
+		tree.Components = cr.trees
+	}
 	// add title part to tree if it is set
 	if s.title != "" {
 		tree.Title = s.title
 		s.title = ""
 	}
+	// add the reply to the event being handled, if any
+	if s.reply != nil {
+		b, err := json.Marshal(s.reply)
+		if err != nil {
+			return nil, fmt.Errorf("encoding reply: %w", err)
+		}
+		tree.Reply = b
+		s.reply = nil
+	}
 	// add events to tree if there are any
 	if len(s.events) > 0 {
 		for _, e := range s.events {
@@ -796,13 +1303,49 @@ func (s *socket) renderToTree(ctx context.Context) (*tmpl.Tree, error) {
 		}
 		s.events = nil
 	}
-	return tree, err
+	return tree, nil
+}
+
+// renderDiff renders the View and returns the JSON diff between the
+// tree last sent to the client and the new one, which replaces it.
+// If no tree has been sent yet, the diff is the full rendered tree.
+func (s *socket) renderDiff(ctx context.Context) ([]byte, error) {
+	t, err := s.renderToTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := tmpl.Diff(s.tree, t)
+	if err != nil {
+		return nil, err
+	}
+	s.tree = t
+	// the client is up to date, so a render postponed for the View's frame rate is moot
+	s.frame.stop()
+	return diff, nil
 }
 
 // Event is the event data sent from the client
 type Event struct {
 	Type string
 	Data url.Values
+	// Kind is the kind of event sent by the client: "click", "keyup", "keydown",
+	// "blur", "focus", "hook" (pushed by a JS hook) or "form". It is empty for
+	// events not sent by the client.
+	Kind string
+	// Raw holds the event's value exactly as the client sent it: a JSON object of the
+	// phx-value-* attributes (and the element's value), or the payload pushed by a hook,
+	// which may be any JSON value. Data only holds the fields of objects.
+	// It is nil for form events, whose fields are in Data.
+	Raw json.RawMessage
+	// Key describes the key pressed for keyup and keydown events, and is nil otherwise.
+	Key *KeyEvent
+	// Target is the name of the form field whose change sent a form event, if any.
+	Target string
+	// Uploads holds the entries of the files selected in a form event's upload input,
+	// keyed by the name of its UploadConfig.
+	Uploads map[string][]UploadEntry
+
+	decoder changeset.Decoder
 }
 
 // MarshalJSON implements json.Marshaler for Event
@@ -836,17 +1379,25 @@ type HTTPHandler struct {
 	config Config
 }
 
-// SendInfo sends an internal event to the View if it is connected to a WebSocket
+// SendInfo sends an internal event to the View if it is connected to a WebSocket.
+// The Info is queued in the View's mailbox and handled by HandleInfo once the View
+// is done with what it is doing, so SendInfo never blocks and may be called from
+// the View's own methods. If the mailbox is full, Config.MailboxOverflow applies.
+// SendInfo does nothing once the View has terminated.
 func SendInfo(ctx context.Context, info *Info) {
 	s := socketValue(ctx)
-	if s == nil {
+	if s == nil || !s.connected {
 		return
 	}
-	// TODO should we do this in a goroutine?
-	s.info <- info
+	view := viewContext(ctx)
+	if view == nil || view.Err() != nil {
+		return
+	}
+	s.send(view, info)
 }
 
-// PageTitle updates the page title for the View
+// PageTitle updates the page title for the View.
+// During the initial HTTP render it sets the Title of the LayoutDot's PageTitle.
 func PageTitle(ctx context.Context, newTitle string) {
 	s := socketValue(ctx)
 	if s == nil {
@@ -862,53 +1413,100 @@ const (
 	NavRedirect LiveNavType = "live_redirect"
 )
 
-// PushNav supports push patching and push redirecting from server to View
+// PushNav supports push patching and push redirecting from server to View.
+// It does nothing unless the View is connected.
+//
+// NavPatch updates the URL without leaving the View, calling its HandleParams method
+// with the new URL, and returns an error if the URL is routed to another type of View.
+// NavRedirect navigates to a URL routed to any View: the client leaves the current View,
+// which terminates, and joins the new one over the same websocket.
 func PushNav(ctx context.Context, typ LiveNavType, path string, params url.Values, replaceHistory bool) error {
 	s := socketValue(ctx)
-	if s == nil {
+	if s == nil || !s.connected {
 		return nil
 	}
 	// build new URL from existing URL and new path and params
-	url := url.URL{Path: path, RawQuery: params.Encode()}
-	to := s.url.ResolveReference(&url)
+	url, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+	if len(params) > 0 {
+		q := url.Query()
+		maps.Copy(q, params)
+		url.RawQuery = q.Encode()
+	}
+	to := s.url.ResolveReference(url)
 
 	kind := "push"
 	if replaceHistory {
 		kind = "replace"
 	}
 
-	// call HandleParams if view implements ParamsHandler
-	hp, ok := s.view.(ParamsHandler)
-	if ok {
-		err := hp.HandleParams(ctx, to)
+	if typ == NavPatch {
+		// patches only change the params of the current View
+		v, route, err := s.viewForURL(to, s.view)
 		if err != nil {
 			return err
 		}
+		if !sameView(v, s.view) {
+			return fmt.Errorf("live: cannot patch to %v, which is not routed to %T; use NavRedirect", to, s.view)
+		}
+		s.url = *to
+		s.route = route
+		// call HandleParams if view implements ParamsHandler
+		hp, ok := s.view.(ParamsHandler)
+		if ok {
+			err := hp.HandleParams(ctx, to)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	// send nav event to view
 	p := phx.NavPayload{Kind: kind, To: to.String()}
-	nm := phx.NewNav(s.id, string(typ), p)
-
-	// don't block waiting for nav channel to be read
-	go func() { s.nav <- nm }()
-	return nil
+	if typ == NavRedirect {
+		// carry flash messages over to the view we're redirecting to
+		flash, err := s.config.flashToken(s.flash)
+		if err != nil {
+			return err
+		}
+		p.Flash = flash
+	}
+	b, err := phx.NewNav(s.id, string(typ), p).JSON()
+	if err != nil {
+		return err
+	}
+	return s.enqueue(b)
 }
 
 // Redirect sends an event to the View that triggers a full page load to url.
+// It does nothing unless the View is connected.
 func Redirect(ctx context.Context, url *url.URL) error {
 	s := socketValue(ctx)
-	if s == nil {
+	if s == nil || !s.connected {
 		return nil
 	}
 	s.redirect = url.String()
 	return nil
 }
 
-// PushEvent sends an event to the View which a Hook can respond to
+// Reply sets the reply to the event being handled by HandleEvent, which is passed to the
+// callback of the JS hook that pushed it (i.e. this.pushEvent(event, payload, (reply) => ...)).
+// The reply must be JSON-encodable. It does nothing unless the View is connected.
+func Reply(ctx context.Context, reply map[string]any) {
+	s := socketValue(ctx)
+	if s == nil || !s.connected {
+		return
+	}
+	s.reply = reply
+}
+
+// PushEvent sends an event to the View which a Hook can respond to.
+// It does nothing unless the View is connected.
 func PushEvent(ctx context.Context, e Event) error {
 	s := socketValue(ctx)
-	if s == nil {
+	if s == nil || !s.connected {
 		return nil
 	}
 	// queue event to be sent to view
@@ -916,6 +1514,16 @@ func PushEvent(ctx context.Context, e Event) error {
 	return nil
 }
 
+// Connected reports whether the View associated with ctx is connected to a websocket.
+// Mount, HandleParams and Render are first called during the initial HTTP render,
+// when Connected is false, and again once the client joins over a websocket.
+// Start long-lived work such as tickers and subscriptions only when Connected is true,
+// otherwise it is started twice and leaks from the HTTP render.
+func Connected(ctx context.Context) bool {
+	s := socketValue(ctx)
+	return s != nil && s.connected
+}
+
 type socketContextKey struct{}
 
 // withSocket returns a context built by associating s with ctx.
@@ -944,8 +1552,25 @@ type LayoutDot struct {
 	Static       string
 	LiveViewID   string
 	CSRFToken    string
+	Flash        map[string]string
+	session      *session
+	signer       signer
 	viewTemplate *htmltmpl.Template
 	viewDot      any
+	// for rendering nested layouts; see Layout
+	w     http.ResponseWriter
+	r     *http.Request
+	inner []LayoutFunc
+}
+
+// sessionToken returns the signed session to embed in the LiveView container,
+// bound to d's CSRFToken, which may have been changed by Config.RenderLayout.
+func (d *LayoutDot) sessionToken() (string, error) {
+	if d.session == nil {
+		return "", nil
+	}
+	d.session.CSRF = d.CSRFToken
+	return d.signer.sign(d.session)
 }
 
 func (d *LayoutDot) ExecuteViewTemplate(buf *strings.Builder) error {
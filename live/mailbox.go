@@ -0,0 +1,111 @@
+package live
+
+import (
+	"context"
+	"time"
+)
+
+// OverflowPolicy determines what happens to an Info sent with SendInfo
+// when the View's mailbox is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the Info being sent.
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest queued Info to make room for the Info being sent.
+	DropOldest
+)
+
+// defaultMailboxSize is the default value of Config.MailboxSize.
+const defaultMailboxSize = 64
+
+// mailboxSize returns the number of Infos that may be queued for a View.
+func (c *Config) mailboxSize() int {
+	if c.MailboxSize > 0 {
+		return c.MailboxSize
+	}
+	return defaultMailboxSize
+}
+
+// mail is an Info queued for the View whose context is view.
+type mail struct {
+	view context.Context
+	info *Info
+}
+
+// send queues info for the View whose context is view without blocking,
+// applying the configured OverflowPolicy if the mailbox is full.
+func (s *socket) send(view context.Context, info *Info) {
+	m := mail{view: view, info: info}
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		select {
+		case s.info <- m:
+			return
+		default:
+		}
+		if s.config.MailboxOverflow != DropOldest {
+			return
+		}
+		select {
+		case <-s.info:
+		default:
+		}
+	}
+}
+
+type viewContextKey struct{}
+
+// viewContext returns the context of the joined View, if any, from which ctx is derived.
+func viewContext(ctx context.Context) context.Context {
+	v, _ := ctx.Value(viewContextKey{}).(context.Context)
+	return v
+}
+
+// ViewContext returns the context of the connected View associated with ctx, or nil if
+// the View is not connected. It is done once the View terminates, and is the same for
+// all contexts passed to the View's methods, so packages built on live can use it to
+// tell connected Views apart, e.g. to subscribe each to a topic only once.
+func ViewContext(ctx context.Context) context.Context {
+	return viewContext(ctx)
+}
+
+// SendInfoAfter sends info to the View after d, unless ctx is done by then.
+// It returns a func that stops the timer. Like SendInfo, it does nothing unless the View is connected.
+func SendInfoAfter(ctx context.Context, d time.Duration, info *Info) (stop func()) {
+	if !Connected(ctx) || ctx.Err() != nil {
+		return func() {}
+	}
+	t := time.AfterFunc(d, func() { SendInfo(ctx, info) })
+	unregister := context.AfterFunc(ctx, func() { t.Stop() })
+	return func() {
+		t.Stop()
+		unregister()
+	}
+}
+
+// Every sends info to the View every d until ctx is done, e.g. when the View terminates.
+// It returns a func that stops the ticker. Like SendInfo, it does nothing unless the View is connected.
+func Every(ctx context.Context, d time.Duration, info *Info) (stop func()) {
+	if !Connected(ctx) || ctx.Err() != nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				SendInfo(ctx, info)
+			}
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,141 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+// newMailboxSocket returns a connected socket with a mailbox of the given size,
+// and the context of a View joined to it.
+func newMailboxSocket(size int, policy OverflowPolicy) (*socket, context.Context, context.CancelCauseFunc) {
+	s := &socket{
+		config:    Config{MailboxSize: size, MailboxOverflow: policy},
+		connected: true,
+		done:      make(chan struct{}),
+	}
+	s.info = make(chan mail, s.config.mailboxSize())
+	vctx, cancel := context.WithCancelCause(withSocket(context.Background(), s))
+	return s, context.WithValue(vctx, viewContextKey{}, vctx), cancel
+}
+
+// queued returns the types of the Infos queued in s's mailbox.
+func queued(s *socket) []string {
+	var types []string
+	for {
+		select {
+		case m := <-s.info:
+			types = append(types, m.info.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestMailboxOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		policy OverflowPolicy
+		want   string
+	}{
+		{DropNewest, "[1 2]"},
+		{DropOldest, "[2 3]"},
+	} {
+		s, ctx, _ := newMailboxSocket(2, tc.policy)
+		for i := 1; i <= 3; i++ {
+			SendInfo(ctx, &Info{Type: fmt.Sprint(i)})
+		}
+		if got := fmt.Sprint(queued(s)); got != tc.want {
+			t.Errorf("policy %d: got %s, want %s", tc.policy, got, tc.want)
+		}
+	}
+}
+
+func TestSendInfoAfterTermination(t *testing.T) {
+	s, ctx, cancel := newMailboxSocket(1, DropNewest)
+	cancel(ErrViewLeft)
+	SendInfo(ctx, &Info{Type: "late"})
+	if got := queued(s); len(got) != 0 {
+		t.Fatalf("queued %v after termination", got)
+	}
+
+	s, ctx, _ = newMailboxSocket(1, DropOldest)
+	close(s.done)
+	SendInfo(ctx, &Info{Type: "a"}) // must not block
+	SendInfo(ctx, &Info{Type: "b"})
+	SendInfoAfter(ctx, time.Millisecond, &Info{Type: "c"})
+
+	// no-ops when not connected
+	SendInfo(context.Background(), &Info{Type: "d"})
+	Every(context.Background(), time.Millisecond, &Info{Type: "e"})()
+}
+
+func TestTimers(t *testing.T) {
+	s, ctx, cancel := newMailboxSocket(8, DropNewest)
+
+	SendInfoAfter(ctx, time.Millisecond, &Info{Type: "after"})
+	stop := SendInfoAfter(ctx, time.Millisecond, &Info{Type: "stopped"})
+	stop()
+	Every(ctx, time.Millisecond, &Info{Type: "every"})
+
+	seen := map[string]int{}
+	deadline := time.After(5 * time.Second)
+	for seen["after"] == 0 || seen["every"] < 3 {
+		select {
+		case m := <-s.info:
+			seen[m.info.Type]++
+		case <-deadline:
+			t.Fatalf("timed out, got %v", seen)
+		}
+	}
+	if seen["stopped"] != 0 {
+		t.Fatalf("stopped timer fired")
+	}
+
+	// terminating the View stops its timers
+	cancel(ErrDisconnected)
+	time.Sleep(10 * time.Millisecond)
+	queued(s)
+	time.Sleep(10 * time.Millisecond)
+	if got := queued(s); len(got) != 0 {
+		t.Fatalf("timers still running after termination: %v", got)
+	}
+}
+
+// selfInfoView sends itself an Info from HandleEvent.
+type selfInfoView struct {
+	Infos int
+}
+
+func (v *selfInfoView) HandleEvent(ctx context.Context, e *Event) error {
+	SendInfo(ctx, &Info{Type: "self"})
+	return nil
+}
+
+func (v *selfInfoView) HandleInfo(ctx context.Context, info *Info) error {
+	v.Infos++
+	return nil
+}
+
+func (v *selfInfoView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("self").Parse(`<p>{{ .Infos }}</p>`))
+}
+
+func TestSendInfoFromHandleEvent(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/self": func() View { return new(selfInfoView) },
+	}))
+	page := getPage(t, srv, "/self")
+	conn := dial(t, srv)
+	conn.join(page)
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "go", "value": map[string]any{}})
+	if event, _ := conn.recv(); event != "phx_reply" {
+		t.Fatalf("got %s, want phx_reply", event)
+	}
+	event, payload := conn.recv()
+	if event != "diff" || payload["0"] != "1" {
+		t.Fatalf("got %s %v, want diff with one info handled", event, payload)
+	}
+}
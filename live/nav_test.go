@@ -0,0 +1,106 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+type navView struct {
+	Name       string
+	Query      string
+	terminated error
+}
+
+func (v *navView) HandleParams(ctx context.Context, u *url.URL) error {
+	v.Query = u.Query().Get("q")
+	return nil
+}
+
+func (v *navView) HandleEvent(ctx context.Context, e *Event) error {
+	switch e.Type {
+	case "patch":
+		return PushNav(ctx, NavPatch, e.Data.Get("to"), nil, false)
+	case "redirect":
+		return PushNav(ctx, NavRedirect, e.Data.Get("to"), nil, false)
+	}
+	return nil
+}
+
+func (v *navView) Terminate(ctx context.Context, reason error) {
+	v.terminated = reason
+}
+
+func (v *navView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("nav").Parse(`<p>{{ .Name }} {{ .Query }}</p>`))
+}
+
+type otherNavView struct{ navView }
+
+func TestLiveNavigation(t *testing.T) {
+	a, b := &navView{Name: "a"}, &otherNavView{navView{Name: "b"}}
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/a": func() View { return a },
+		"/b": func() View { return b },
+	}))
+	page := getPage(t, srv, "/a")
+	conn := dial(t, srv)
+	conn.join(page)
+
+	// patching to the same View calls HandleParams
+	conn.send(page.topic, "live_patch", map[string]any{"url": srv.URL + "/a?q=go"})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" || a.Query != "go" {
+		t.Fatalf("patch: got %s %v, query %q", event, payload, a.Query)
+	}
+	// patching to another View has the client redirect instead
+	conn.send(page.topic, "live_patch", map[string]any{"url": srv.URL + "/b"})
+	event, payload := conn.recv()
+	if resp, _ := payload["response"].(map[string]any); event != "phx_reply" || resp["link_redirect"] != true {
+		t.Fatalf("patch to other view: got %s %v, want link_redirect", event, payload)
+	}
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "patch", "value": map[string]any{"to": "/b"}})
+	if event, payload := conn.recv(); event != "phx_error" {
+		t.Fatalf("PushNav patch to other view: got %s %v, want phx_error", event, payload)
+	}
+
+	conn.join(page)
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "redirect", "value": map[string]any{"to": "/b?q=gopher"}})
+	// PushNav queues the redirect while the event is handled, ahead of the reply
+	event, payload = conn.recv()
+	if event != "live_redirect" || payload["to"] != srv.URL+"/b?q=gopher" {
+		t.Fatalf("PushNav redirect: got %s %v, want live_redirect", event, payload)
+	}
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("PushNav redirect: got %s %v, want reply to the event", event, payload)
+	}
+
+	// the client leaves the old View and joins the new one on the same topic
+	conn.send(page.topic, "phx_leave", map[string]any{})
+	if event, _ := conn.recv(); event != "phx_reply" || !errors.Is(a.terminated, ErrViewLeft) {
+		t.Fatalf("leave: got %s, a terminated with %v", event, a.terminated)
+	}
+	conn.send(page.topic, "phx_join", map[string]any{
+		"redirect": payload["to"],
+		"params":   map[string]any{"_csrf_token": page.csrf},
+		"session":  page.session,
+	})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" || b.Query != "gopher" {
+		t.Fatalf("redirect join: got %s %v, b query %q", event, payload, b.Query)
+	}
+
+	// redirects to URLs that are not Views load the page instead
+	conn.send(page.topic, "phx_join", map[string]any{
+		"redirect": srv.URL + "/nowhere",
+		"params":   map[string]any{"_csrf_token": page.csrf},
+		"session":  page.session,
+	})
+	event, payload = conn.recv()
+	resp, _ := payload["response"].(map[string]any)
+	redirect, _ := resp["redirect"].(map[string]any)
+	if event != "phx_reply" || payload["status"] != "error" || redirect["to"] != srv.URL+"/nowhere" {
+		t.Fatalf("redirect to non-view: got %s %v", event, payload)
+	}
+}
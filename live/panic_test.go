@@ -0,0 +1,160 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+	"github.com/canopyclimate/golive/live/internal/phx"
+	"github.com/gorilla/websocket"
+)
+
+// panicView panics in Mount if mount is set, and handling "boom" events.
+type panicView struct {
+	mount  bool
+	Clicks int
+}
+
+func (v *panicView) Mount(ctx context.Context, p Params) error {
+	if v.mount {
+		panic("mount")
+	}
+	return nil
+}
+
+func (v *panicView) HandleEvent(ctx context.Context, e *Event) error {
+	if e.Type == "boom" {
+		var m map[string]int
+		m["boom"]++ // nil map write
+	}
+	v.Clicks++
+	return nil
+}
+
+func (v *panicView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("panic").Parse(`<p>{{ .Clicks }}</p>`))
+}
+
+// recordPanics returns a channel of the errors passed to c.OnViewError,
+// as *PanicErrors if they are, or nil otherwise.
+func recordPanics(t *testing.T, c *Config) <-chan *PanicError {
+	panics := make(chan *PanicError, 10)
+	c.OnViewError = func(ctx context.Context, v View, u *url.URL, err error) {
+		var pe *PanicError
+		if errors.As(err, &pe) && len(pe.Stack) == 0 {
+			t.Errorf("OnViewError: %v has no stack", err)
+		}
+		panics <- pe
+	}
+	return panics
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	c := newTestConfig(map[string]func() View{
+		"/panic": func() View { return &panicView{mount: true} },
+	})
+	panics := recordPanics(t, c)
+	rec := httptest.NewRecorder()
+	c.Middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != 500 || string(body) != "Internal Server Error\n" {
+		t.Errorf("got %d %q, want an internal error page", rec.Code, body)
+	}
+	if pe := <-panics; pe == nil || pe.Value != "mount" {
+		t.Errorf("got panic %v, want mount", pe)
+	}
+
+	// as does a panicking Mux
+	c.Mux.(*http.ServeMux).HandleFunc("/mux", func(w http.ResponseWriter, r *http.Request) {
+		panic("mux")
+	})
+	rec = httptest.NewRecorder()
+	c.Middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("GET", "/mux", nil))
+	if rec.Code != 500 {
+		t.Errorf("got %d, want an internal error page", rec.Code)
+	}
+	if pe := <-panics; pe == nil || pe.Value != "mux" {
+		t.Errorf("got panic %v, want mux", pe)
+	}
+}
+
+func TestSocketPanic(t *testing.T) {
+	c := newTestConfig(map[string]func() View{
+		"/panic": func() View { return new(panicView) },
+	})
+	panics := recordPanics(t, c)
+	srv := newTestServer(t, c)
+	page := getPage(t, srv, "/panic")
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join: got %s %v", event, payload)
+	}
+
+	// a panicking View errors, so that the client rejoins
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "boom", "value": map[string]any{}})
+	if event, payload := conn.recv(); event != "phx_error" {
+		t.Fatalf("panicking event: got %s %v, want phx_error", event, payload)
+	}
+	if pe := <-panics; pe == nil {
+		t.Errorf("panicking event: got an error, want a panic")
+	}
+
+	// and the socket is still served
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("rejoin: got %s %v", event, payload)
+	}
+	conn.send(page.topic, "event", map[string]any{"type": "click", "event": "inc", "value": map[string]any{}})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("event: got %s %v", event, payload)
+	}
+}
+
+func TestMalformedMessages(t *testing.T) {
+	c := newTestConfig(map[string]func() View{
+		"/panic": func() View { return new(panicView) },
+	})
+	panics := recordPanics(t, c)
+	srv := newTestServer(t, c)
+	page := getPage(t, srv, "/panic")
+	for _, tt := range []struct {
+		event   string
+		payload map[string]any
+	}{
+		{"event", map[string]any{"type": 1}},
+		{"event", map[string]any{"type": "click"}},
+		{"event", map[string]any{"type": "form", "value": "a=1"}},
+		{"live_patch", map[string]any{"url": 1}},
+		{"allow_upload", map[string]any{"ref": "r", "entries": "e"}},
+		{"progress", map[string]any{"ref": "r", "entry_ref": "e"}},
+	} {
+		// errors are throttled per socket, so each gets its own
+		conn := dial(t, srv)
+		conn.join(page)
+		conn.send(page.topic, tt.event, tt.payload)
+		if event, payload := conn.recv(); event != "phx_error" {
+			t.Fatalf("malformed %s %v: got %s %v, want phx_error", tt.event, tt.payload, event, payload)
+		}
+		if pe := <-panics; pe != nil {
+			t.Errorf("malformed %s %v: got panic %v, want an error", tt.event, tt.payload, pe.Value)
+		}
+	}
+	// upload chunks are not JSON, but their topic must still name the upload
+	conn := dial(t, srv)
+	conn.join(page)
+	chunk, _ := phx.UploadMsg{JoinRef: "1", MsgRef: "2", Topic: "lvu", Event: "chunk", Payload: []byte("data")}.MarshalBinary()
+	err := conn.conn.WriteMessage(websocket.BinaryMessage, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event, payload := conn.recv(); event != "phx_error" {
+		t.Fatalf("chunk without a ref: got %s %v, want phx_error", event, payload)
+	}
+	if pe := <-panics; pe != nil {
+		t.Errorf("chunk without a ref: got panic %v, want an error", pe.Value)
+	}
+}
@@ -0,0 +1,52 @@
+package presence
+
+import "sync"
+
+// Memory is a Backend that stores presences in memory, for a single server.
+type Memory struct {
+	mu     sync.Mutex
+	topics map[string]map[string]presence // by topic, then ref
+}
+
+type presence struct {
+	key  string
+	meta Meta
+}
+
+// NewMemory returns a new in-memory Backend.
+func NewMemory() *Memory {
+	return &Memory{topics: make(map[string]map[string]presence)}
+}
+
+// Track implements Backend.
+func (m *Memory) Track(topic, key string, meta Meta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.topics[topic] == nil {
+		m.topics[topic] = make(map[string]presence)
+	}
+	m.topics[topic][meta.Ref] = presence{key: key, meta: meta}
+	return nil
+}
+
+// Untrack implements Backend.
+func (m *Memory) Untrack(topic, ref string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.topics[topic], ref)
+	if len(m.topics[topic]) == 0 {
+		delete(m.topics, topic)
+	}
+	return nil
+}
+
+// List implements Backend.
+func (m *Memory) List(topic string) (Presences, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ps := make(Presences)
+	for _, p := range m.topics[topic] {
+		ps[p.key] = append(ps[p.key], p.meta)
+	}
+	return ps, nil
+}
@@ -0,0 +1,175 @@
+// Package presence tracks who is present on a topic, e.g. the users viewing a record,
+// and tells the Views subscribed to the topic as they come and go.
+//
+// A View tracks its user and subscribes to the topic's diffs from Mount:
+//
+//	func (v *Doc) Mount(ctx context.Context, p live.Params) error {
+//		topic := "doc:" + v.ID
+//		_, err := pubsub.Subscribe(ctx, topic)
+//		if err != nil {
+//			return err
+//		}
+//		_, err = presence.Track(ctx, topic, v.UserID, map[string]any{"name": v.UserName})
+//		if err != nil {
+//			return err
+//		}
+//		v.Viewers, err = presence.List(topic)
+//		return err
+//	}
+//
+// and applies diffs as they arrive:
+//
+//	func (v *Doc) HandleInfo(ctx context.Context, info *live.Info) error {
+//		if d, ok := presence.DiffFrom(info); ok {
+//			v.Viewers.Apply(d)
+//		}
+//		return nil
+//	}
+//
+// A presence is removed when the View that tracked it terminates.
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/canopyclimate/golive/live"
+	"github.com/canopyclimate/golive/live/pubsub"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+// Meta describes a single presence of a key, e.g. one of a user's open tabs.
+type Meta struct {
+	// Ref uniquely identifies the presence.
+	Ref string `json:"ref"`
+	// Data is the data the presence was tracked with.
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Presences maps the keys present on a topic (e.g. user IDs) to their presences.
+type Presences map[string][]Meta
+
+// Diff describes the presences that joined and left a topic.
+type Diff struct {
+	Joins  Presences `json:"joins,omitempty"`
+	Leaves Presences `json:"leaves,omitempty"`
+}
+
+// Apply updates p with the joins and leaves in d.
+// Keys with no presences left are removed.
+func (p Presences) Apply(d *Diff) {
+	for key, metas := range d.Joins {
+		for _, m := range metas {
+			if !slices.ContainsFunc(p[key], func(pm Meta) bool { return pm.Ref == m.Ref }) {
+				p[key] = append(p[key], m)
+			}
+		}
+	}
+	for key, metas := range d.Leaves {
+		for _, m := range metas {
+			p[key] = slices.DeleteFunc(p[key], func(pm Meta) bool { return pm.Ref == m.Ref })
+		}
+		if len(p[key]) == 0 {
+			delete(p, key)
+		}
+	}
+}
+
+// Backend stores the presences on each topic.
+// Implementations backed by a shared store allow presence to span servers;
+// they are responsible for removing the presences of servers that go away.
+type Backend interface {
+	// Track records that key is present on topic with meta.
+	Track(topic, key string, meta Meta) error
+	// Untrack removes the presence with ref from topic.
+	Untrack(topic, ref string) error
+	// List returns the presences on topic.
+	List(topic string) (Presences, error)
+}
+
+// DiffInfoType is the Type of the Infos carrying Diffs; see DiffFrom.
+const DiffInfoType = "presence_diff"
+
+// Presence tracks presences using a Backend and broadcasts Diffs to the topic they are
+// tracked on using a PubSub.
+type Presence struct {
+	backend Backend
+	pubsub  *pubsub.PubSub
+}
+
+// New returns a Presence using backend and broadcasting with ps.
+func New(backend Backend, ps *pubsub.PubSub) *Presence {
+	return &Presence{backend: backend, pubsub: ps}
+}
+
+// Default is the Presence used by Track and List.
+// It uses an in-memory Backend and broadcasts with pubsub.Default.
+var Default = New(NewMemory(), pubsub.Default)
+
+// Track tracks the View associated with ctx as a presence of key on topic, with data.
+// Views subscribed to topic receive a Diff with the join, and another with the leave
+// once the View terminates or untrack is called.
+// Track does nothing unless the View is connected; see live.Connected.
+func (p *Presence) Track(ctx context.Context, topic, key string, data map[string]any) (untrack func(), err error) {
+	if !live.Connected(ctx) || ctx.Err() != nil {
+		return func() {}, nil
+	}
+	meta := Meta{Ref: uuid.New().String(), Data: data}
+	err = p.backend.Track(topic, key, meta)
+	if err != nil {
+		return nil, err
+	}
+	err = p.broadcast(topic, &Diff{Joins: Presences{key: {meta}}})
+	if err != nil {
+		return nil, err
+	}
+	leave := func() {
+		// the View is gone, so there's no one to report errors to
+		_ = p.backend.Untrack(topic, meta.Ref)
+		_ = p.broadcast(topic, &Diff{Leaves: Presences{key: {meta}}})
+	}
+	stop := context.AfterFunc(ctx, leave)
+	return func() {
+		if stop() {
+			leave()
+		}
+	}, nil
+}
+
+// List returns the presences on topic.
+func (p *Presence) List(topic string) (Presences, error) {
+	return p.backend.List(topic)
+}
+
+func (p *Presence) broadcast(topic string, d *Diff) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return p.pubsub.Broadcast(topic, &live.Info{Type: DiffInfoType, Data: url.Values{"diff": {string(b)}}})
+}
+
+// DiffFrom returns the Diff carried by info, if any.
+func DiffFrom(info *live.Info) (*Diff, bool) {
+	if info.Type != DiffInfoType {
+		return nil, false
+	}
+	d := new(Diff)
+	err := json.Unmarshal([]byte(info.Data.Get("diff")), d)
+	if err != nil {
+		return nil, false
+	}
+	return d, true
+}
+
+// Track tracks the View associated with ctx on topic using Default.
+func Track(ctx context.Context, topic, key string, data map[string]any) (untrack func(), err error) {
+	return Default.Track(ctx, topic, key, data)
+}
+
+// List returns the presences on topic using Default.
+func List(topic string) (Presences, error) {
+	return Default.List(topic)
+}
@@ -0,0 +1,127 @@
+package presence
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+	"github.com/canopyclimate/golive/live"
+	"github.com/canopyclimate/golive/live/internal/livetest"
+	"github.com/canopyclimate/golive/live/pubsub"
+	"github.com/gorilla/websocket"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+type docView struct {
+	p       *Presence
+	ps      *pubsub.PubSub
+	user    string
+	Viewers Presences
+}
+
+func (v *docView) Mount(ctx context.Context, p live.Params) error {
+	_, err := v.ps.Subscribe(ctx, "doc")
+	if err != nil {
+		return err
+	}
+	_, err = v.p.Track(ctx, "doc", v.user, map[string]any{"name": strings.ToUpper(v.user)})
+	if err != nil {
+		return err
+	}
+	v.Viewers, err = v.p.List("doc")
+	return err
+}
+
+func (v *docView) HandleInfo(ctx context.Context, info *live.Info) error {
+	if d, ok := DiffFrom(info); ok {
+		v.Viewers.Apply(d)
+	}
+	return nil
+}
+
+func (v *docView) Render(ctx context.Context, meta *live.Meta) (any, *htmltmpl.Template) {
+	keys := maps.Keys(v.Viewers)
+	slices.Sort(keys)
+	return strings.Join(keys, ","), htmltmpl.Must(htmltmpl.New("doc").Parse(`<p>{{ . }}</p>`))
+}
+
+func newServer(t *testing.T, p *Presence) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/doc/{user}", func(w http.ResponseWriter, r *http.Request) {
+		live.SetView(r, &docView{p: p, ps: p.pubsub, user: r.PathValue("user")})
+	})
+	return livetest.NewServer(t, mux)
+}
+
+// waitFor reads diffs from conn until one renders viewers.
+func waitFor(t *testing.T, conn *websocket.Conn, viewers string) {
+	t.Helper()
+	for {
+		event, payload := livetest.Recv(t, conn)
+		if event != "diff" {
+			t.Fatalf("got %s %v, want diff", event, payload)
+		}
+		if payload["0"] == viewers {
+			return
+		}
+	}
+}
+
+func TestPresence(t *testing.T) {
+	mem := NewMemory()
+	p := New(mem, pubsub.New(pubsub.NewMemory()))
+	srv := newServer(t, p)
+
+	alice := livetest.Join(t, srv, "/doc/alice")
+	bob := livetest.Join(t, srv, "/doc/bob")
+	waitFor(t, alice, "alice,bob")
+
+	ps, err := p.List("doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 2 || len(ps["bob"]) != 1 || ps["bob"][0].Data["name"] != "BOB" {
+		t.Fatalf("got presences %v", ps)
+	}
+
+	// bob's presence goes away with his connection
+	bob.UnderlyingConn().Close()
+	waitFor(t, alice, "alice")
+	ps, err = p.List("doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 1 || ps["alice"] == nil {
+		t.Fatalf("got presences %v", ps)
+	}
+}
+
+func TestApply(t *testing.T) {
+	a1, a2, b := Meta{Ref: "a1"}, Meta{Ref: "a2"}, Meta{Ref: "b"}
+	ps := Presences{"a": {a1}}
+	ps.Apply(&Diff{Joins: Presences{"a": {a1, a2}, "b": {b}}})
+	if len(ps["a"]) != 2 || len(ps["b"]) != 1 {
+		t.Fatalf("after joins got %v", ps)
+	}
+	ps.Apply(&Diff{Leaves: Presences{"a": {a1}, "b": {b}}})
+	if len(ps) != 1 || len(ps["a"]) != 1 || ps["a"][0].Ref != a2.Ref {
+		t.Fatalf("after leaves got %v", ps)
+	}
+}
+
+func TestTrackNotConnected(t *testing.T) {
+	mem := NewMemory()
+	p := New(mem, pubsub.New(pubsub.NewMemory()))
+	untrack, err := p.Track(context.Background(), "doc", "alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrack()
+	if ps, _ := p.List("doc"); len(ps) != 0 {
+		t.Fatalf("got presences %v", ps)
+	}
+}
@@ -0,0 +1,62 @@
+package pubsub
+
+import (
+	"sync"
+
+	"github.com/canopyclimate/golive/live"
+)
+
+// Memory is a Backend that delivers Infos to subscribers in the same process.
+type Memory struct {
+	mu   sync.RWMutex
+	subs map[string]map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	fn func(*live.Info)
+}
+
+// NewMemory returns a new in-memory Backend.
+func NewMemory() *Memory {
+	return &Memory{subs: make(map[string]map[*subscriber]struct{})}
+}
+
+// Subscribe implements Backend.
+func (m *Memory) Subscribe(topic string, fn func(*live.Info)) (unsubscribe func(), err error) {
+	sub := &subscriber{fn: fn}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.subs[topic] == nil {
+		m.subs[topic] = make(map[*subscriber]struct{})
+	}
+	m.subs[topic][sub] = struct{}{}
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subs[topic], sub)
+		if len(m.subs[topic]) == 0 {
+			delete(m.subs, topic)
+		}
+	}, nil
+}
+
+// Publish implements Backend.
+func (m *Memory) Publish(topic string, info *live.Info) error {
+	m.mu.RLock()
+	subs := make([]*subscriber, 0, len(m.subs[topic]))
+	for sub := range m.subs[topic] {
+		subs = append(subs, sub)
+	}
+	m.mu.RUnlock()
+	for _, sub := range subs {
+		sub.fn(info)
+	}
+	return nil
+}
+
+// Subscribers returns the number of subscribers to topic.
+func (m *Memory) Subscribers(topic string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.subs[topic])
+}
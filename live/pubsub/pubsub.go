@@ -0,0 +1,108 @@
+// Package pubsub broadcasts Infos to the connected Views subscribed to a topic,
+// e.g. so every user viewing an order sees it update as soon as it changes.
+//
+// Views subscribe from Mount:
+//
+//	func (v *Order) Mount(ctx context.Context, p live.Params) error {
+//		_, err := pubsub.Subscribe(ctx, "order:"+v.ID)
+//		return err
+//	}
+//
+// and anything in the server can then broadcast to them:
+//
+//	pubsub.Broadcast("order:"+id, &live.Info{Type: "order_updated"})
+//
+// Each subscribed View receives the Info via its HandleInfo method.
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/canopyclimate/golive/live"
+)
+
+// Backend delivers Infos published to a topic to the topic's subscribers.
+// Implementations backed by a message broker (e.g. Redis or Kafka) allow
+// broadcasting across servers.
+type Backend interface {
+	// Subscribe arranges for fn to be called with every Info published to topic
+	// until unsubscribe is called. fn must not block.
+	Subscribe(topic string, fn func(*live.Info)) (unsubscribe func(), err error)
+	// Publish delivers info to all subscribers of topic.
+	Publish(topic string, info *live.Info) error
+}
+
+// PubSub subscribes Views to topics and broadcasts Infos to them using a Backend.
+type PubSub struct {
+	backend Backend
+	mu      sync.Mutex
+	subs    map[subscription]func() // unsubscribe funcs of the subscriptions of Views
+}
+
+// subscription is the subscription of a View, identified by its live.ViewContext, to a topic.
+type subscription struct {
+	view  context.Context
+	topic string
+}
+
+// New returns a PubSub using backend.
+func New(backend Backend) *PubSub {
+	return &PubSub{backend: backend, subs: make(map[subscription]func())}
+}
+
+// Default is the PubSub used by Subscribe and Broadcast. It uses an in-memory Backend.
+var Default = New(NewMemory())
+
+// Subscribe subscribes the View associated with ctx to topic.
+// Infos broadcast to topic are sent to the View as with live.SendInfo.
+// The View is unsubscribed when it terminates or unsubscribe is called.
+// Subscribing a View to a topic it is already subscribed to, e.g. from HandleParams,
+// does nothing but return the same unsubscribe func, so each Info is sent only once.
+// Subscribe does nothing unless the View is connected; see live.Connected.
+func (ps *PubSub) Subscribe(ctx context.Context, topic string) (unsubscribe func(), err error) {
+	if !live.Connected(ctx) || ctx.Err() != nil {
+		return func() {}, nil
+	}
+	key := subscription{view: live.ViewContext(ctx), topic: topic}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if unsubscribe, ok := ps.subs[key]; ok {
+		return unsubscribe, nil
+	}
+	unsub, err := ps.backend.Subscribe(topic, func(info *live.Info) {
+		live.SendInfo(ctx, info)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cleanup := sync.OnceFunc(func() {
+		unsub()
+		ps.mu.Lock()
+		defer ps.mu.Unlock()
+		delete(ps.subs, key)
+	})
+	stop := context.AfterFunc(ctx, cleanup)
+	unsubscribe = func() {
+		stop()
+		cleanup()
+	}
+	ps.subs[key] = unsubscribe
+	return unsubscribe, nil
+}
+
+// Broadcast sends info to every View subscribed to topic.
+// Subscribers share info, so it must not be modified after it is broadcast.
+func (ps *PubSub) Broadcast(topic string, info *live.Info) error {
+	return ps.backend.Publish(topic, info)
+}
+
+// Subscribe subscribes the View associated with ctx to topic using Default.
+func Subscribe(ctx context.Context, topic string) (unsubscribe func(), err error) {
+	return Default.Subscribe(ctx, topic)
+}
+
+// Broadcast sends info to every View subscribed to topic using Default.
+func Broadcast(topic string, info *live.Info) error {
+	return Default.Broadcast(topic, info)
+}
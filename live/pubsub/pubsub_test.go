@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+	"github.com/canopyclimate/golive/live"
+	"github.com/canopyclimate/golive/live/internal/livetest"
+	"github.com/gorilla/websocket"
+)
+
+type roomView struct {
+	ps   *PubSub
+	Last string
+}
+
+func (v *roomView) Mount(ctx context.Context, p live.Params) error {
+	_, err := v.ps.Subscribe(ctx, "room")
+	return err
+}
+
+// HandleParams subscribes again, as Views that subscribe to topics depending on their
+// params do, which must not deliver Infos twice.
+func (v *roomView) HandleParams(ctx context.Context, u *url.URL) error {
+	_, err := v.ps.Subscribe(ctx, "room")
+	return err
+}
+
+func (v *roomView) HandleInfo(ctx context.Context, info *live.Info) error {
+	v.Last = info.Type
+	return nil
+}
+
+func (v *roomView) Render(ctx context.Context, meta *live.Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("room").Parse(`<p>{{ .Last }}</p>`))
+}
+
+func newServer(t *testing.T, ps *PubSub) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/room", func(w http.ResponseWriter, r *http.Request) {
+		live.SetView(r, &roomView{ps: ps})
+	})
+	return livetest.NewServer(t, mux)
+}
+
+func TestBroadcast(t *testing.T) {
+	mem := NewMemory()
+	ps := New(mem)
+	srv := newServer(t, ps)
+
+	a := livetest.Join(t, srv, "/room")
+	b := livetest.Join(t, srv, "/room")
+	// the HTTP renders didn't subscribe
+	if n := mem.Subscribers("room"); n != 2 {
+		t.Fatalf("got %d subscribers, want 2", n)
+	}
+
+	err := ps.Broadcast("room", &live.Info{Type: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range []*websocket.Conn{a, b} {
+		event, payload := livetest.Recv(t, conn)
+		if event != "diff" || payload["0"] != "hello" {
+			t.Fatalf("got %s %v, want diff with hello", event, payload)
+		}
+	}
+
+	// disconnecting unsubscribes
+	a.UnderlyingConn().Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for mem.Subscribers("room") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d subscribers after disconnect, want 1", mem.Subscribers("room"))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSubscribeTwice(t *testing.T) {
+	mem := NewMemory()
+	ps := New(mem)
+	srv := newServer(t, ps)
+
+	conn := livetest.Join(t, srv, "/room")
+	if n := mem.Subscribers("room"); n != 1 {
+		t.Fatalf("got %d subscribers, want 1", n)
+	}
+	err := ps.Broadcast("room", &live.Info{Type: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event, payload := livetest.Recv(t, conn); event != "diff" || payload["0"] != "hello" {
+		t.Fatalf("got %s %v, want diff with hello", event, payload)
+	}
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, msg, err := conn.ReadMessage(); err == nil {
+		t.Fatalf("got %s, want a single diff", msg)
+	}
+}
+
+func TestMemoryUnsubscribe(t *testing.T) {
+	mem := NewMemory()
+	var got []string
+	unsub, err := mem.Subscribe("t", func(info *live.Info) { got = append(got, info.Type) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem.Publish("t", &live.Info{Type: "a"})
+	mem.Publish("other", &live.Info{Type: "b"})
+	unsub()
+	mem.Publish("t", &live.Info{Type: "c"})
+	if len(got) != 1 || got[0] != "a" {
+		t.Fatalf("got %v, want [a]", got)
+	}
+	if n := mem.Subscribers("t"); n != 0 {
+		t.Fatalf("got %d subscribers, want 0", n)
+	}
+
+	// not connected
+	unsub, err = Subscribe(context.Background(), "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsub()
+}
@@ -41,6 +41,30 @@ func SetView(r *http.Request, v View) {
 	container.r = r
 }
 
+// SetLiveSession marks the View r is routed to as part of the live session named name.
+// The client can live redirect between the Views of a live session over its websocket,
+// joining the new View with the session of the page it loaded. Live redirects to Views
+// outside of it, or if r is not part of any, load the page instead, so that Middleware
+// renders the View and whatever authorizes it runs again.
+//
+// Views sharing a live session should be authorized alike, e.g. by the same middleware.
+func SetLiveSession(r *http.Request, name string) {
+	container, ok := r.Context().Value(liveViewRequestContextKey{}).(*liveViewContainer)
+	if !ok {
+		return
+	}
+	container.liveSession = name
+}
+
+// liveSessionName returns the live session of the View r was routed to; see SetLiveSession.
+func liveSessionName(r *http.Request) string {
+	container, ok := r.Context().Value(liveViewRequestContextKey{}).(*liveViewContainer)
+	if !ok {
+		return ""
+	}
+	return container.liveSession
+}
+
 // GetView returns the View of type T corresponding to r.
 // If no such view has been set, returns the zero value for T.
 func GetView[T View](r *http.Request) T {
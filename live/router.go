@@ -0,0 +1,177 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+// A Router routes requests to Views using the patterns of http.ServeMux,
+// e.g. "/users/{id}" or "GET /files/{path...}", and builds URLs for named routes.
+// Use it as a Config's Mux:
+//
+//	rt := live.NewRouter()
+//	rt.Handle("/users/{id}", func() live.View { return new(UserProfile) }).Name("user")
+//	config := live.Config{Mux: rt, ...}
+//
+// Views read the values of the wildcards in their route with PathValue.
+type Router struct {
+	mux   *http.ServeMux
+	names map[string]string // route name -> pattern
+}
+
+// NewRouter returns a new Router with no routes.
+func NewRouter() *Router {
+	return &Router{
+		mux:   http.NewServeMux(),
+		names: make(map[string]string),
+	}
+}
+
+// Handle routes requests matching pattern to the View returned by newView.
+// Patterns are those of http.ServeMux; as with it, Handle panics if pattern
+// is invalid or conflicts with that of another route.
+//
+// newView is called for every request routed to the View, except that a View patched
+// to a URL matching a route of its own type is kept, and its HandleParams method called.
+func (rt *Router) Handle(pattern string, newView func() View) *Route {
+	route := &Route{rt: rt, pattern: pattern}
+	rt.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		v := newView()
+		if current := GetView[View](r); sameView(v, current) {
+			v = current
+		}
+		SetView(r, v)
+		SetLiveSession(r, route.liveSession)
+	})
+	return route
+}
+
+// ServeHTTP routes r to its View; see SetView.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Path returns the path of the route with the given name, filling in its wildcards
+// from pairs of wildcard names and values. Values are formatted with fmt.Sprint and
+// escaped; pairs not naming a wildcard are added as query params. For example:
+//
+//	rt.Path("user", "id", 42, "tab", "posts") // "/users/42?tab=posts"
+//
+// Pass the path to PushNav or the liveNav template func (see Funcs) to navigate to it.
+func (rt *Router) Path(name string, pairs ...any) (string, error) {
+	pattern, ok := rt.names[name]
+	if !ok {
+		return "", fmt.Errorf("live: no route named %q", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("live: odd number of arguments building path for route %q", name)
+	}
+	vals := make(map[string]string, len(pairs)/2)
+	var keys []string
+	for i := 0; i < len(pairs); i += 2 {
+		k, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("live: path wildcard names must be strings, got %T", pairs[i])
+		}
+		if _, ok := vals[k]; !ok {
+			keys = append(keys, k)
+		}
+		vals[k] = fmt.Sprint(pairs[i+1])
+	}
+
+	// drop the method and host, if any
+	if _, p, ok := strings.Cut(pattern, " "); ok {
+		pattern = strings.TrimLeft(p, " \t")
+	}
+	pattern = pattern[strings.Index(pattern, "/"):]
+
+	segs := strings.Split(pattern, "/")
+	for i, seg := range segs {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		wc := seg[1 : len(seg)-1]
+		if wc == "$" {
+			segs[i] = ""
+			continue
+		}
+		wc, multi := strings.CutSuffix(wc, "...")
+		v, ok := vals[wc]
+		if !ok {
+			return "", fmt.Errorf("live: missing value for {%s} in path for route %q", wc, name)
+		}
+		delete(vals, wc)
+		if multi {
+			parts := strings.Split(v, "/")
+			for j, p := range parts {
+				parts[j] = url.PathEscape(p)
+			}
+			segs[i] = strings.Join(parts, "/")
+		} else {
+			segs[i] = url.PathEscape(v)
+		}
+	}
+	path := strings.Join(segs, "/")
+
+	q := url.Values{}
+	for _, k := range keys {
+		if v, ok := vals[k]; ok {
+			q.Set(k, v)
+		}
+	}
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	return path, nil
+}
+
+// Funcs provides template funcs for building URLs with rt:
+//   - livePath: returns the path of a named route; see Router.Path
+//
+// For example, {{ liveNav "navigate" (livePath "user" "id" .User.ID) nil "Profile" }}.
+func (rt *Router) Funcs() htmltmpl.FuncMap {
+	return htmltmpl.FuncMap{
+		"livePath": rt.Path,
+	}
+}
+
+// A Route is a route registered with Router.Handle.
+type Route struct {
+	rt          *Router
+	pattern     string
+	liveSession string
+}
+
+// Name names the route, so that its URLs can be built with Router.Path.
+// It panics if the name is already used by another route.
+func (r *Route) Name(name string) *Route {
+	if p, ok := r.rt.names[name]; ok && p != r.pattern {
+		panic(fmt.Sprintf("live: route name %q used for both %q and %q", name, p, r.pattern))
+	}
+	r.rt.names[name] = r.pattern
+	return r
+}
+
+// LiveSession puts the route in the named live session, so that the client can
+// live redirect between its View and those of the other routes in it; see SetLiveSession.
+func (r *Route) LiveSession(name string) *Route {
+	r.liveSession = name
+	return r
+}
+
+// PathValue returns the value of the named wildcard in the pattern of the route
+// the View associated with ctx was routed with, as with http.Request.PathValue.
+// It returns "" if there is no such wildcard, or if the Config's Mux does not
+// use http.ServeMux patterns.
+func PathValue(ctx context.Context, name string) string {
+	s := socketValue(ctx)
+	if s == nil || s.route == nil {
+		return ""
+	}
+	return s.route.PathValue(name)
+}
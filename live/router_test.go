@@ -0,0 +1,149 @@
+package live
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+func TestRouterPath(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("/users/{id}", func() View { return new(userView) }).Name("user")
+	rt.Handle("GET example.com/files/{path...}", func() View { return new(userView) }).Name("file")
+	rt.Handle("/{$}", func() View { return new(userView) }).Name("home")
+
+	tests := []struct {
+		name  string
+		pairs []any
+		want  string
+	}{
+		{"user", []any{"id", 42}, "/users/42"},
+		{"user", []any{"id", "a b/c", "tab", "posts"}, "/users/a%20b%2Fc?tab=posts"},
+		{"file", []any{"path", "docs/read me.txt"}, "/files/docs/read%20me.txt"},
+		{"home", nil, "/"},
+	}
+	for _, test := range tests {
+		got, err := rt.Path(test.name, test.pairs...)
+		if err != nil {
+			t.Errorf("Path(%q, %v): %v", test.name, test.pairs, err)
+		} else if got != test.want {
+			t.Errorf("Path(%q, %v) = %q, want %q", test.name, test.pairs, got, test.want)
+		}
+	}
+	for _, pairs := range [][]any{nil, {"id"}, {1, 2}} {
+		if _, err := rt.Path("user", pairs...); err == nil {
+			t.Errorf("Path(user, %v): no error", pairs)
+		}
+	}
+	if _, err := rt.Path("nope"); err == nil {
+		t.Error("Path(nope): no error")
+	}
+}
+
+type userView struct {
+	ID     string
+	Tab    string
+	Mounts int
+}
+
+func (v *userView) Mount(ctx context.Context, p Params) error {
+	v.Mounts++
+	return nil
+}
+
+func (v *userView) HandleParams(ctx context.Context, u *url.URL) error {
+	v.ID = PathValue(ctx, "id")
+	v.Tab = u.Query().Get("tab")
+	return nil
+}
+
+func (v *userView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("user").Parse(`<p>user {{ .ID }} {{ .Tab }}</p>`))
+}
+
+func TestRouter(t *testing.T) {
+	rt := NewRouter()
+	var views []*userView
+	rt.Handle("GET /users/{id}", func() View {
+		v := new(userView)
+		views = append(views, v)
+		return v
+	}).Name("user")
+	c := newTestConfig(nil)
+	c.Mux = rt
+	srv := newTestServer(t, c)
+
+	page := getPage(t, srv, "/users/7")
+	if !strings.Contains(page.body, "user 7") {
+		t.Fatalf("HTTP render: got %s", page.body)
+	}
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join: got %s %v", event, payload)
+	}
+	joined := views[len(views)-1]
+	if joined.ID != "7" {
+		t.Fatalf("join: got id %q, want 7", joined.ID)
+	}
+
+	path, err := rt.Path("user", "id", 8, "tab", "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.send(page.topic, "live_patch", map[string]any{"url": srv.URL + path})
+	if event, payload := conn.recv(); event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("patch: got %s %v", event, payload)
+	}
+	if joined.ID != "8" || joined.Tab != "posts" || joined.Mounts != 1 {
+		t.Errorf("patch: got %+v, want id 8, tab posts, mounted once", joined)
+	}
+
+	// other requests fall through to the next handler
+	if page := getPage(t, srv, "/posts/1"); strings.Contains(page.body, "user") {
+		t.Errorf("unrouted request: got %s", page.body)
+	}
+}
+
+func TestRouterLiveSessions(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("/a", func() View { return &navView{Name: "a"} }).LiveSession("app")
+	rt.Handle("/b", func() View { return &otherNavView{navView{Name: "b"}} }).LiveSession("app")
+	rt.Handle("/admin", func() View { return new(plainView) }).LiveSession("admin")
+	rt.Handle("/plain", func() View { return new(plainView) })
+	c := newTestConfig(nil)
+	c.Mux = rt
+	srv := newTestServer(t, c)
+
+	redirectJoin := func(from, to string) (string, map[string]any) {
+		page := getPage(t, srv, from)
+		conn := dial(t, srv)
+		conn.send(page.topic, "phx_join", map[string]any{
+			"redirect": srv.URL + to,
+			"params":   map[string]any{"_csrf_token": page.csrf},
+			"session":  page.session,
+		})
+		return conn.recv()
+	}
+	for _, tt := range []struct {
+		from, to string
+		ok       bool
+	}{
+		{"/a", "/b", true},
+		{"/a", "/admin", false},
+		{"/a", "/plain", false},
+		{"/plain", "/a", false},
+	} {
+		event, payload := redirectJoin(tt.from, tt.to)
+		resp, _ := payload["response"].(map[string]any)
+		redirect, _ := resp["redirect"].(map[string]any)
+		switch {
+		case tt.ok && (event != "phx_reply" || payload["status"] != "ok"):
+			t.Errorf("live redirect from %s to %s: got %s %v, want ok", tt.from, tt.to, event, payload)
+		case !tt.ok && redirect["to"] != srv.URL+tt.to:
+			t.Errorf("live redirect from %s to %s: got %s %v, want page load", tt.from, tt.to, event, payload)
+		}
+	}
+}
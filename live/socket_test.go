@@ -0,0 +1,286 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+	"github.com/gorilla/websocket"
+)
+
+// newTestConfig returns a Config routing paths to Views, all in the same live session.
+func newTestConfig(routes map[string]func() View) *Config {
+	mux := http.NewServeMux()
+	for path, fn := range routes {
+		fn := fn
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			SetView(r, fn())
+			SetLiveSession(r, "test")
+		})
+	}
+	layout := htmltmpl.Must(htmltmpl.New("layout").Funcs(Funcs()).Parse(
+		`<title>{{ .PageTitle.Title }}</title><meta name="csrf-token" content="{{ .CSRFToken }}" />{{ liveViewContainerTag . }}`,
+	))
+	return &Config{
+		Mux: mux,
+		RenderLayout: func(w http.ResponseWriter, r *http.Request, ld *LayoutDot) (any, *htmltmpl.Template) {
+			return ld, layout
+		},
+	}
+}
+
+// newTestServer serves c's views over HTTP and their websocket at /live/websocket.
+func newTestServer(t *testing.T, c *Config) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/live/websocket", NewWebsocketHandler(*c))
+	mux.Handle("/", c.Middleware(http.NotFoundHandler()))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testPage is the data a client needs from an HTTP render to join its View.
+type testPage struct {
+	url     string
+	topic   string
+	session string
+	csrf    string
+	params  map[string]any // extra connect params
+	body    string
+}
+
+// attr returns the value of the first attribute named name in body.
+func attr(body, name string) string {
+	_, rest, _ := strings.Cut(body, name+`="`)
+	v, _, _ := strings.Cut(rest, `"`)
+	return v
+}
+
+func getPage(t *testing.T, srv *httptest.Server, path string) testPage {
+	t.Helper()
+	req, err := http.NewRequest("GET", srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return getPageRequest(t, srv, req)
+}
+
+func getPageRequest(t *testing.T, srv *httptest.Server, req *http.Request) testPage {
+	t.Helper()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	buf := new(strings.Builder)
+	_, err = io.Copy(buf, res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := buf.String()
+	return testPage{
+		url:     req.URL.String(),
+		topic:   "lv:" + attr(body, "id"),
+		session: attr(body, "data-phx-session"),
+		csrf:    attr(body, `name="csrf-token" content`),
+		body:    body,
+	}
+}
+
+// testConn is a websocket client speaking the Phoenix protocol.
+type testConn struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dial(t *testing.T, srv *httptest.Server) *testConn {
+	t.Helper()
+	return dialHeader(t, srv, nil)
+}
+
+func dialHeader(t *testing.T, srv *httptest.Server, header http.Header) *testConn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/live/websocket", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testConn{t: t, conn: conn}
+}
+
+func (c *testConn) send(topic, event string, payload map[string]any) {
+	c.t.Helper()
+	err := c.conn.WriteJSON([]any{"1", "1", topic, event, payload})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// recv reads a message and returns its event and payload.
+func (c *testConn) recv() (string, map[string]any) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg []json.RawMessage
+	err := c.conn.ReadJSON(&msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var event string
+	var payload map[string]any
+	if err := json.Unmarshal(msg[3], &event); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := json.Unmarshal(msg[4], &payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return event, payload
+}
+
+func (c *testConn) join(p testPage) (string, map[string]any) {
+	c.t.Helper()
+	params := map[string]any{"_csrf_token": p.csrf, "_mounts": 0}
+	for k, v := range p.params {
+		params[k] = v
+	}
+	c.send(p.topic, "phx_join", map[string]any{
+		"url":     p.url,
+		"params":  params,
+		"session": p.session,
+	})
+	return c.recv()
+}
+
+type sessionView struct {
+	User string
+}
+
+func (v *sessionView) Mount(ctx context.Context, p Params) error {
+	v.User, _ = p.Session["user"].(string)
+	return nil
+}
+
+func (v *sessionView) HandleEvent(ctx context.Context, e *Event) error {
+	return nil
+}
+
+func (v *sessionView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("session").Parse(`<p>{{ .User }}</p>`))
+}
+
+func newSessionConfig() *Config {
+	c := newTestConfig(map[string]func() View{
+		"/session": func() View { return new(sessionView) },
+		"/other":   func() View { return new(replyView) },
+	})
+	c.SecretKey = []byte("0123456789abcdef0123456789abcdef")
+	c.SessionData = func(r *http.Request) map[string]any {
+		return map[string]any{"user": "gopher"}
+	}
+	return c
+}
+
+func TestJoinSession(t *testing.T) {
+	srv := newTestServer(t, newSessionConfig())
+	page := getPage(t, srv, "/session")
+	conn := dial(t, srv)
+	event, payload := conn.join(page)
+	if event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join failed: %s %v", event, payload)
+	}
+	rendered := payload["response"].(map[string]any)["rendered"].(map[string]any)
+	if rendered["0"] != "gopher" {
+		t.Fatalf("session data not passed to Mount, rendered %v", rendered)
+	}
+}
+
+func TestJoinRejected(t *testing.T) {
+	c := newSessionConfig()
+	srv := newTestServer(t, c)
+	tests := []struct {
+		name   string
+		page   func(testPage) testPage
+		reason string
+	}{
+		{"tampered", func(p testPage) testPage { p.session = "x" + p.session; return p }, joinUnauthorized},
+		{"missing", func(p testPage) testPage { p.session = ""; return p }, joinUnauthorized},
+		{"csrf", func(p testPage) testPage { p.csrf = "bogus"; return p }, joinUnauthorized},
+		{"topic", func(p testPage) testPage { p.topic = "lv:phx-other"; return p }, joinUnauthorized},
+		{"view", func(p testPage) testPage { p.url = strings.Replace(p.url, "/session", "/other", 1); return p }, joinUnauthorized},
+		{"stale", func(p testPage) testPage {
+			sess := &session{
+				ID:      strings.TrimPrefix(p.topic, "lv:phx-"),
+				View:    "*live.sessionView",
+				CSRF:    p.csrf,
+				Expires: time.Now().Add(-time.Minute).Unix(),
+			}
+			p.session, _ = c.signer().sign(sess)
+			return p
+		}, joinStale},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := test.page(getPage(t, srv, "/session"))
+			conn := dial(t, srv)
+			event, payload := conn.join(page)
+			if event != "phx_reply" || payload["status"] != "error" {
+				t.Fatalf("join not rejected: %s %v", event, payload)
+			}
+			if reason := payload["response"].(map[string]any)["reason"]; reason != test.reason {
+				t.Fatalf("got reason %v, want %v", reason, test.reason)
+			}
+			// no View handles the client's events after all
+			conn.send(page.topic, "event", map[string]any{"type": "click", "event": "inc", "value": map[string]any{}})
+			if event, payload := conn.recv(); event != "phx_error" {
+				t.Fatalf("event after rejected join: got %s %v, want phx_error", event, payload)
+			}
+		})
+	}
+}
+
+type replyView struct{}
+
+func (v *replyView) HandleEvent(ctx context.Context, e *Event) error {
+	if e.Type == "suggest" {
+		Reply(ctx, map[string]any{"suggestions": []string{e.Data.Get("q") + "pher"}})
+	}
+	return nil
+}
+
+func (v *replyView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("reply").Parse(`<input phx-hook="Suggest">`))
+}
+
+func TestEventReply(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/reply": func() View { return new(replyView) },
+	}))
+	page := getPage(t, srv, "/reply")
+	conn := dial(t, srv)
+	conn.join(page)
+
+	for _, tc := range []struct {
+		event string
+		want  any
+	}{
+		{"suggest", map[string]any{"suggestions": []any{"gopher"}}},
+		{"other", nil}, // replies don't leak into later events
+	} {
+		conn.send(page.topic, "event", map[string]any{"type": "hook", "event": tc.event, "value": map[string]any{"q": "go"}})
+		event, payload := conn.recv()
+		if event != "phx_reply" {
+			t.Fatalf("got %s %v, want phx_reply", event, payload)
+		}
+		diff, _ := payload["response"].(map[string]any)["diff"].(map[string]any)
+		if got := diff["r"]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got reply %v, want %v", tc.event, got, tc.want)
+		}
+	}
+}
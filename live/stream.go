@@ -0,0 +1,170 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// A StreamItem is an item of a Stream.
+type StreamItem interface {
+	// DOMID returns the id of the element rendering the item, which must be unique
+	// within the page, e.g. "songs-" + song.ID.
+	DOMID() string
+}
+
+// StreamOption configures a Stream operation.
+type StreamOption func(*streamOptions)
+
+type streamOptions struct {
+	at    int
+	reset bool
+}
+
+// StreamAt inserts items at index i of the stream's element instead of appending them.
+// 0 prepends and -1 appends.
+func StreamAt(i int) StreamOption {
+	return func(o *streamOptions) { o.at = i }
+}
+
+// StreamReset removes all items from the stream's element before inserting the given ones,
+// by having the client delete every item inserted into the stream so far.
+func StreamReset() StreamOption {
+	return func(o *streamOptions) { o.reset = true }
+}
+
+// stream tracks a Stream of a View between renders.
+type stream struct {
+	entries []StreamEntry       // items to insert on the next render
+	deletes []string            // DOM IDs to delete on the next render
+	ids     map[string]struct{} // DOM IDs of the items in the client, for resets
+}
+
+// Stream inserts items into the View's stream with the given name.
+//
+// Streams manage large collections without keeping them in memory: the items passed to
+// Stream are rendered once and sent to the client, which inserts them into the stream's
+// element, and then forgotten, but for their DOM IDs (see StreamReset). Inserting an item
+// whose DOM ID is already in the stream updates it in place. Render a stream by ranging
+// over it in a phx-update="stream" element, using each entry's ID as the id of its element:
+//
+//	<ul id="songs" phx-update="stream">
+//		{{ range .Meta.Streams.songs }}<li id="{{ .ID }}">{{ .Item.Title }}</li>{{ end }}
+//	</ul>
+func Stream[T StreamItem](ctx context.Context, name string, items []T, opts ...StreamOption) {
+	st := streamValue(ctx, name)
+	if st == nil {
+		return
+	}
+	o := streamOptions{at: -1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.reset {
+		st.entries = nil
+		ids := maps.Keys(st.ids)
+		slices.Sort(ids)
+		st.deletes = append(st.deletes, ids...)
+		clear(st.ids)
+	}
+	for _, item := range items {
+		id := item.DOMID()
+		st.entries = slices.DeleteFunc(st.entries, func(e StreamEntry) bool { return e.ID == id })
+		st.entries = append(st.entries, StreamEntry{ID: id, Item: item, at: o.at})
+		st.ids[id] = struct{}{}
+	}
+}
+
+// StreamInsert inserts item into the View's stream with the given name; see Stream.
+func StreamInsert[T StreamItem](ctx context.Context, name string, item T, opts ...StreamOption) {
+	Stream(ctx, name, []T{item}, opts...)
+}
+
+// StreamDelete deletes item from the View's stream with the given name.
+func StreamDelete(ctx context.Context, name string, item StreamItem) {
+	StreamDeleteByID(ctx, name, item.DOMID())
+}
+
+// StreamDeleteByID deletes the item with the given DOM ID from the View's stream with the given name.
+func StreamDeleteByID(ctx context.Context, name, id string) {
+	st := streamValue(ctx, name)
+	if st == nil {
+		return
+	}
+	st.entries = slices.DeleteFunc(st.entries, func(e StreamEntry) bool { return e.ID == id })
+	st.deletes = append(st.deletes, id)
+	delete(st.ids, id)
+}
+
+// streamValue returns the stream with the given name of the View associated with ctx,
+// creating it if needed, or nil if there is no View.
+func streamValue(ctx context.Context, name string) *stream {
+	s := socketValue(ctx)
+	if s == nil {
+		return nil
+	}
+	if s.streams == nil {
+		s.streams = make(map[string]*stream)
+	}
+	st, ok := s.streams[name]
+	if !ok {
+		st = &stream{ids: make(map[string]struct{})}
+		s.streams[name] = st
+	}
+	return st
+}
+
+// StreamEntry is an item of a Stream being rendered.
+type StreamEntry struct {
+	// ID is the DOM ID of the item.
+	ID string
+	// Item is the item passed to Stream.
+	Item any
+	at   int
+}
+
+// StreamUpdate holds the changes to a Stream since it was last rendered.
+// Range over it in a template to render the inserted items as StreamEntries.
+type StreamUpdate struct {
+	entries []StreamEntry
+	deletes []string
+}
+
+// TreeStream returns the items to render and the changes to send to the client
+// when a template ranges over u.
+func (u *StreamUpdate) TreeStream() (items any, payload []byte) {
+	if u == nil {
+		return nil, nil
+	}
+	if len(u.entries) == 0 && len(u.deletes) == 0 {
+		return u.entries, nil
+	}
+	inserts := make(map[string]int, len(u.entries))
+	for _, e := range u.entries {
+		inserts[e.ID] = e.at
+	}
+	deletes := u.deletes
+	if deletes == nil {
+		deletes = []string{}
+	}
+	payload, err := json.Marshal([]any{inserts, deletes})
+	if err != nil {
+		panic(err) // cannot happen with maps of strings to ints and slices of strings
+	}
+	return u.entries, payload
+}
+
+// streamUpdates returns the pending updates to s's streams for rendering and clears them.
+func (s *socket) streamUpdates() map[string]*StreamUpdate {
+	if len(s.streams) == 0 {
+		return nil
+	}
+	updates := make(map[string]*StreamUpdate, len(s.streams))
+	for name, st := range s.streams {
+		updates[name] = &StreamUpdate{entries: st.entries, deletes: st.deletes}
+		st.entries, st.deletes = nil, nil
+	}
+	return updates
+}
@@ -0,0 +1,107 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+type logLine struct {
+	N    string
+	Text string
+}
+
+func (l logLine) DOMID() string { return "log-" + l.N }
+
+type logView struct{}
+
+func (v *logView) Mount(ctx context.Context, p Params) error {
+	Stream(ctx, "logs", []logLine{{"1", "one"}, {"2", "two"}})
+	return nil
+}
+
+func (v *logView) HandleEvent(ctx context.Context, e *Event) error {
+	switch e.Type {
+	case "prepend":
+		StreamInsert(ctx, "logs", logLine{"3", "three"}, StreamAt(0))
+	case "update":
+		StreamInsert(ctx, "logs", logLine{"1", "uno"})
+	case "delete":
+		StreamDelete(ctx, "logs", logLine{N: "2"})
+	case "reset":
+		Stream(ctx, "logs", []logLine{{"4", "four"}}, StreamReset())
+	}
+	return nil
+}
+
+func (v *logView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return meta, htmltmpl.Must(htmltmpl.New("logs").Parse(
+		`<ul id="logs" phx-update="stream">{{ range .Streams.logs }}<li id="{{ .ID }}">{{ .Item.Text }}</li>{{ end }}</ul>`,
+	))
+}
+
+// streamPayload returns the items and stream payload of the stream in rendered, as JSON.
+func streamPayload(t *testing.T, rendered map[string]any) (string, string) {
+	t.Helper()
+	node, ok := rendered["0"].(map[string]any)
+	if !ok {
+		t.Fatalf("no stream in %v", rendered)
+	}
+	d, err := json.Marshal(node["d"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := json.Marshal(node["stream"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(d), string(st)
+}
+
+func TestStream(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/logs": func() View { return new(logView) },
+	}))
+	page := getPage(t, srv, "/logs")
+	if want := `<li id="log-1">one</li><li id="log-2">two</li>`; !strings.Contains(page.body, want) {
+		t.Fatalf("HTTP render missing %q:\n%s", want, page.body)
+	}
+
+	conn := dial(t, srv)
+	_, payload := conn.join(page)
+	d, st := streamPayload(t, payload["response"].(map[string]any)["rendered"].(map[string]any))
+	if want := `[["log-1","one"],["log-2","two"]]`; d != want {
+		t.Errorf("join: got items %s, want %s", d, want)
+	}
+	if want := `[{"log-1":-1,"log-2":-1},[]]`; st != want {
+		t.Errorf("join: got stream %s, want %s", st, want)
+	}
+
+	for _, tc := range []struct {
+		event  string
+		wantD  string
+		wantSt string
+	}{
+		{"prepend", `[["log-3","three"]]`, `[{"log-3":0},[]]`},
+		{"update", `[["log-1","uno"]]`, `[{"log-1":-1},[]]`},
+		{"delete", `[]`, `[{},["log-2"]]`},
+		{"reset", `[["log-4","four"]]`, `[{"log-4":-1},["log-1","log-3"]]`},
+	} {
+		conn.send(page.topic, "event", map[string]any{"type": "click", "event": tc.event, "value": map[string]any{}})
+		event, payload := conn.recv()
+		if event != "phx_reply" {
+			t.Fatalf("%s: got %s %v", tc.event, event, payload)
+		}
+		diff := payload["response"].(map[string]any)["diff"].(map[string]any)
+		d, st := streamPayload(t, diff)
+		if d != tc.wantD {
+			t.Errorf("%s: got items %s, want %s", tc.event, d, tc.wantD)
+		}
+		if st != tc.wantSt {
+			t.Errorf("%s: got stream %s, want %s", tc.event, st, tc.wantSt)
+		}
+	}
+}
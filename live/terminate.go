@@ -0,0 +1,79 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Reasons passed to Terminator.Terminate.
+var (
+	// ErrViewLeft is the reason a View terminates when the client leaves it,
+	// e.g. when navigating to another View.
+	ErrViewLeft = errors.New("live: view left")
+	// ErrDisconnected is the reason a View terminates when its websocket disconnects,
+	// e.g. when the tab is closed or the network drops. It is usually wrapped
+	// with the underlying error; use errors.Is to check for it.
+	ErrDisconnected = errors.New("live: websocket disconnected")
+	// ErrHeartbeatTimeout is the reason, wrapped along with ErrDisconnected, a View terminates
+	// when nothing is heard from its client for Config.HeartbeatTimeout, e.g. when the
+	// connection silently died.
+	ErrHeartbeatTimeout = errors.New("live: heartbeat timeout")
+	// ErrSlowConsumer is the reason, wrapped along with ErrDisconnected, a View terminates
+	// when its client falls too far behind reading messages; see Config.SlowConsumerPolicy.
+	ErrSlowConsumer = errors.New("live: slow consumer")
+	// ErrJoinFailed is the reason a View terminates when it fails to join, i.e. its Mount
+	// or HandleParams method, or rendering it, fails or panics. It is usually wrapped
+	// with the error it failed with; use errors.Is to check for it.
+	ErrJoinFailed = errors.New("live: join failed")
+)
+
+// Terminator is an interface that can be implemented by a View to be notified
+// when it is torn down, along with the reason why (see ErrViewLeft, ErrDisconnected, ErrHeartbeatTimeout,
+// ErrSlowConsumer and ErrJoinFailed).
+// Terminate is called exactly once for every View that joined over a websocket,
+// whichever way its session ends.
+//
+// The context passed to a connected View's methods is cancelled, with reason as its
+// cause (see context.Cause), once Terminate returns. Goroutines started by the View
+// should stop when it is done.
+//
+// A View implementing io.Closer is closed at the same point, after Terminate.
+type Terminator interface {
+	Terminate(ctx context.Context, reason error)
+}
+
+// terminate tears down the socket's current View, if it has not been already,
+// calling its Terminate and Close methods and cancelling its context.
+func (s *socket) terminate(reason error) error {
+	if s.cancelView == nil {
+		return nil
+	}
+	defer func() {
+		s.cancelView(reason)
+		s.cancelView = nil
+		s.frame.stop()
+	}()
+	if t, ok := s.view.(Terminator); ok {
+		t.Terminate(s.viewCtx, reason)
+	}
+	if c, ok := s.view.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// discardView tears down a View that failed to join with err, or panicked if err is nil,
+// and removes it from the socket, so that it cannot be sent any other message.
+func (s *socket) discardView(err error) {
+	reason := ErrJoinFailed
+	if err != nil {
+		reason = fmt.Errorf("%w: %w", ErrJoinFailed, err)
+	}
+	cerr := protect(func() error { return s.terminate(reason) })
+	if cerr != nil && s.config.OnViewError != nil {
+		s.config.OnViewError(s.viewCtx, s.view, &s.url, cerr)
+	}
+	s.view, s.route = nil, nil
+}
@@ -0,0 +1,167 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+)
+
+// tickView sends itself infos from a goroutine until its context is done,
+// like a View driven by a time.Ticker.
+type tickView struct {
+	terminated chan error // Terminate reasons
+	stopped    chan error // cause of the context stopping the goroutine
+}
+
+func newTickView() *tickView {
+	return &tickView{
+		terminated: make(chan error, 2),
+		stopped:    make(chan error, 1),
+	}
+}
+
+func (v *tickView) Mount(ctx context.Context, p Params) error {
+	if !Connected(ctx) {
+		return nil
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				v.stopped <- context.Cause(ctx)
+				return
+			case <-time.After(time.Millisecond):
+				SendInfo(ctx, &Info{Type: "tick"})
+			}
+		}
+	}()
+	return nil
+}
+
+func (v *tickView) HandleInfo(ctx context.Context, info *Info) error {
+	return nil
+}
+
+func (v *tickView) Terminate(ctx context.Context, reason error) {
+	if ctx.Err() != nil {
+		panic("context cancelled before Terminate")
+	}
+	v.terminated <- reason
+}
+
+func (v *tickView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("tick").Parse(`<p>tick</p>`))
+}
+
+// wait returns the next value received from c, failing the test if there is none within 5s.
+func wait(t *testing.T, c chan error, what string) error {
+	t.Helper()
+	select {
+	case err := <-c:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+		return nil
+	}
+}
+
+// joinTickView joins a new tickView over a new connection on the returned topic.
+func joinTickView(t *testing.T) (*tickView, *testConn, string) {
+	t.Helper()
+	v := newTickView()
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/tick": func() View { return v },
+	}))
+	page := getPage(t, srv, "/tick")
+	conn := dial(t, srv)
+	event, payload := conn.join(page)
+	if event != "phx_reply" || payload["status"] != "ok" {
+		t.Fatalf("join failed: %s %v", event, payload)
+	}
+	return v, conn, page.topic
+}
+
+func TestTerminateOnDisconnect(t *testing.T) {
+	v, conn, _ := joinTickView(t)
+
+	// drop the connection mid-session without a close handshake
+	conn.conn.UnderlyingConn().Close()
+
+	reason := wait(t, v.terminated, "Terminate")
+	if !errors.Is(reason, ErrDisconnected) {
+		t.Fatalf("got reason %v, want %v", reason, ErrDisconnected)
+	}
+	if cause := wait(t, v.stopped, "context cancellation"); cause != reason {
+		t.Fatalf("got context cause %v, want %v", cause, reason)
+	}
+	select {
+	case reason := <-v.terminated:
+		t.Fatalf("Terminate called twice, again with %v", reason)
+	default:
+	}
+}
+
+func TestTerminateOnLeave(t *testing.T) {
+	v, conn, topic := joinTickView(t)
+
+	conn.send(topic, "phx_leave", map[string]any{})
+	for {
+		event, payload := conn.recv()
+		if event == "phx_reply" {
+			if payload["status"] != "ok" {
+				t.Fatalf("leave failed: %v", payload)
+			}
+			break
+		}
+	}
+	if reason := wait(t, v.terminated, "Terminate"); reason != ErrViewLeft {
+		t.Fatalf("got reason %v, want %v", reason, ErrViewLeft)
+	}
+	if cause := wait(t, v.stopped, "context cancellation"); cause != ErrViewLeft {
+		t.Fatalf("got context cause %v, want %v", cause, ErrViewLeft)
+	}
+
+	// disconnecting after leaving doesn't terminate the View again
+	conn.conn.UnderlyingConn().Close()
+	select {
+	case reason := <-v.terminated:
+		t.Fatalf("Terminate called twice, again with %v", reason)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// forbiddenTickView is a tickView that is not allowed to join once mounted.
+type forbiddenTickView struct {
+	*tickView
+}
+
+func (v forbiddenTickView) HandleParams(ctx context.Context, u *url.URL) error {
+	if !Connected(ctx) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func TestTerminateOnFailedJoin(t *testing.T) {
+	v := forbiddenTickView{newTickView()}
+	srv := newTestServer(t, newTestConfig(map[string]func() View{
+		"/tick": func() View { return v },
+	}))
+	page := getPage(t, srv, "/tick")
+	conn := dial(t, srv)
+	if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "error" {
+		t.Fatalf("join: got %s %v, want error", event, payload)
+	}
+
+	reason := wait(t, v.terminated, "Terminate")
+	if !errors.Is(reason, ErrJoinFailed) || !errors.Is(reason, ErrForbidden) {
+		t.Fatalf("got reason %v, want %v with %v", reason, ErrJoinFailed, ErrForbidden)
+	}
+	if cause := wait(t, v.stopped, "context cancellation"); cause != reason {
+		t.Fatalf("got context cause %v, want %v", cause, reason)
+	}
+}
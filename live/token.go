@@ -0,0 +1,168 @@
+package live
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canopyclimate/golive/live/internal/phx"
+)
+
+// errInvalidToken is returned when a token fails verification.
+var errInvalidToken = errors.New("invalid token")
+
+// signer signs and verifies tokens carrying JSON-encoded data.
+// Tokens are of the form base64(json) + "." + base64(hmac-sha256(json)),
+// which is safe for use in cookies, URLs and HTML attributes.
+type signer struct {
+	key []byte
+}
+
+// sign returns a token carrying v.
+func (s signer) sign(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(b) + "." + enc.EncodeToString(s.mac(b)), nil
+}
+
+// verify checks that token was created by sign with the same key and decodes its data into v.
+func (s signer) verify(token string, v any) error {
+	data, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return errInvalidToken
+	}
+	enc := base64.RawURLEncoding
+	b, err := enc.DecodeString(data)
+	if err != nil {
+		return errInvalidToken
+	}
+	mac, err := enc.DecodeString(sig)
+	if err != nil {
+		return errInvalidToken
+	}
+	if !hmac.Equal(mac, s.mac(b)) {
+		return errInvalidToken
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (s signer) mac(b []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+var processKey = sync.OnceValue(func() []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return key
+})
+
+// session is the data carried from the HTTP render to the websocket join
+// in the data-phx-session attribute of the LiveView container.
+type session struct {
+	ID          string            `json:"id"`   // LiveView container ID, without the "phx-" prefix
+	View        string            `json:"view"` // type of the rendered View
+	CSRF        string            `json:"csrf"` // CSRF token the client must join with
+	Data        map[string]any    `json:"data,omitempty"`
+	Flash       map[string]string `json:"flash,omitempty"`
+	Layout      string            `json:"layout,omitempty"`
+	LiveSession string            `json:"live,omitempty"` // live session the client may live redirect within
+	Expires     int64             `json:"exp"`            // Unix time after which the session is stale
+}
+
+// defaultSessionMaxAge is the default value of Config.SessionMaxAge.
+const defaultSessionMaxAge = 14 * 24 * time.Hour
+
+// sessionMaxAge returns how long sessions signed by c are valid for.
+func (c *Config) sessionMaxAge() time.Duration {
+	if c.SessionMaxAge > 0 {
+		return c.SessionMaxAge
+	}
+	return defaultSessionMaxAge
+}
+
+// verifySession verifies the session token sent by the client when joining topic.
+// It rejects the join if the token is invalid, stale, or was not issued for topic.
+func (c *Config) verifySession(msg *phx.Msg) (*session, error) {
+	token, _ := msg.Payload["session"].(string)
+	sess := new(session)
+	err := c.signer().verify(token, sess)
+	if err != nil {
+		return nil, rejectJoin(msg, joinUnauthorized, fmt.Errorf("verifying session: %w", err))
+	}
+	if time.Now().Unix() > sess.Expires {
+		return nil, rejectJoin(msg, joinStale, fmt.Errorf("session expired at %v", time.Unix(sess.Expires, 0)))
+	}
+	if msg.Topic != "lv:phx-"+sess.ID {
+		return nil, rejectJoin(msg, joinUnauthorized, fmt.Errorf("session for %q used to join %q", sess.ID, msg.Topic))
+	}
+	return sess, nil
+}
+
+// Reasons for rejecting a join. The javascript client reloads the page for
+// the first two, and for others reloads it after a growing delay.
+const (
+	joinUnauthorized = "unauthorized"
+	joinStale        = "stale"
+	joinRateLimited  = "rate_limited"
+)
+
+// joinRejectedError is returned when a join is rejected. Instead of a
+// "phx_error", the client receives a join error reply with the reason,
+// or telling it to load the redirect URL.
+type joinRejectedError struct {
+	msg      phx.Msg
+	reason   string
+	redirect string
+	err      error
+}
+
+func rejectJoin(msg *phx.Msg, reason string, err error) error {
+	return &joinRejectedError{msg: *msg, reason: reason, err: err}
+}
+
+// redirectJoin rejects a join that failed with err wrapping ErrNotFound or ErrForbidden,
+// having the client load the page at to, which renders the error page (see Config.RenderError),
+// rather than retry the join. Other errors are returned as is, so that the client
+// retries the join after a "phx_error", as throttled by the socket.
+func redirectJoin(msg *phx.Msg, to string, err error) error {
+	if HTTPStatus(err) == http.StatusInternalServerError {
+		return err
+	}
+	return &joinRejectedError{msg: *msg, redirect: to, err: err}
+}
+
+func (e *joinRejectedError) Error() string {
+	if e.redirect != "" {
+		return fmt.Sprintf("join failed: %v", e.err)
+	}
+	return fmt.Sprintf("join rejected (%s): %v", e.reason, e.err)
+}
+
+func (e *joinRejectedError) Unwrap() error {
+	return e.err
+}
+
+// signer returns the signer used for c's tokens.
+// If c has no SecretKey, tokens are signed with a random key generated once per process.
+func (c *Config) signer() signer {
+	if len(c.SecretKey) > 0 {
+		return signer{key: c.SecretKey}
+	}
+	return signer{key: processKey()}
+}
@@ -0,0 +1,33 @@
+package live
+
+import (
+	"testing"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	s := signer{key: []byte("secret")}
+	token, err := s.sign(map[string]string{"info": "Saved!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	err = s.verify(token, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["info"] != "Saved!" {
+		t.Fatalf("got %v", got)
+	}
+
+	other := signer{key: []byte("other")}
+	if err := other.verify(token, &got); err == nil {
+		t.Fatal("verified token signed with a different key")
+	}
+	tampered := "x" + token
+	if err := s.verify(tampered, &got); err == nil {
+		t.Fatal("verified tampered token")
+	}
+	if err := s.verify("garbage", &got); err == nil {
+		t.Fatal("verified garbage token")
+	}
+}
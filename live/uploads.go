@@ -196,6 +196,8 @@ type ConsumeUploadedEntriesMeta struct {
 
 // Allows file uploads for the given `LiveView`and configures the upload
 // options (filetypes, size, etc).
+// Uploads allowed before the View is connected are only used for rendering the
+// initial HTML; files can only be uploaded once Mount has run again on join.
 func AllowUpload(ctx context.Context, name string, options UploadConstraints) error {
 	uc := &UploadConfig{
 		Ref:               "phx-" + uuid.New().String(),
@@ -0,0 +1,187 @@
+package live
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/canopyclimate/golive/live/internal/phx"
+	"github.com/gorilla/websocket"
+)
+
+// defaultWriteQueueSize is the default value of Config.WriteQueueSize.
+const defaultWriteQueueSize = 256
+
+// writeQueueSize returns the number of messages that may be queued for a socket's writer.
+func (c *Config) writeQueueSize() int {
+	if c.WriteQueueSize > 0 {
+		return c.WriteQueueSize
+	}
+	return defaultWriteQueueSize
+}
+
+// SlowConsumerPolicy determines what happens when a websocket's client reads its messages
+// so slowly that its write queue fills up; see Config.WriteQueueSize.
+type SlowConsumerPolicy int
+
+const (
+	// WaitForSlowConsumer makes the View wait for room in the queue, for up to
+	// Config.WriteTimeout, before disconnecting the client. Meanwhile the View
+	// handles nothing else, so its other messages queue up in turn.
+	WaitForSlowConsumer SlowConsumerPolicy = iota
+	// DisconnectSlowConsumer disconnects the client as soon as its queue is full.
+	DisconnectSlowConsumer
+)
+
+// enqueue queues the message m to be written to the client by writeLoop.
+// It returns an error wrapping ErrSlowConsumer if the queue stays full, as per
+// Config.SlowConsumerPolicy, or the error writing failed with.
+func (s *socket) enqueue(m []byte) error {
+	select {
+	case s.out <- m:
+		return nil
+	case <-s.writerDone:
+		return s.writeErr
+	default:
+	}
+	if s.config.SlowConsumerPolicy == WaitForSlowConsumer {
+		t := time.NewTimer(s.config.writeTimeout())
+		defer t.Stop()
+		select {
+		case s.out <- m:
+			return nil
+		case <-s.writerDone:
+			return s.writeErr
+		case <-t.C:
+		}
+	}
+	s.queues.slowConsumers.Add(1)
+	return fmt.Errorf("%w: %d messages queued", ErrSlowConsumer, len(s.out))
+}
+
+// writeLoop writes the messages queued by enqueue, and pings the client every
+// Config.PingInterval, until the socket is done or writing fails, in which case
+// s.writeErr is set and s.writerDone closed.
+func (s *socket) writeLoop() {
+	defer close(s.writerDone)
+
+	var pings <-chan time.Time
+	if d := s.config.pingInterval(); d > 0 {
+		t := time.NewTicker(d)
+		defer t.Stop()
+		pings = t.C
+	}
+
+	for {
+		select {
+		case m := <-s.out:
+			err := s.writeBatch(m)
+			if err != nil {
+				s.writeErr = fmt.Errorf("websocket write: %v", err)
+				return
+			}
+		case <-pings:
+			err := s.ping()
+			if err != nil {
+				s.writeErr = fmt.Errorf("websocket ping: %v", err)
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// writeBatch writes m, followed by the messages queued meanwhile, up to the size of
+// the queue, within one write deadline of Config.WriteTimeout. Consecutive diffs of
+// the View are coalesced into one message (see phx.MergeDiffs), so that a client
+// catching up patches its DOM once rather than for every render it missed.
+func (s *socket) writeBatch(m []byte) error {
+	err := s.conn.SetWriteDeadline(time.Now().Add(s.config.writeTimeout()))
+	if err != nil {
+		return err
+	}
+	for n := cap(s.out); n > 0; n-- {
+		var next []byte
+		select {
+		case next = <-s.out:
+		default:
+			return s.conn.WriteMessage(websocket.TextMessage, m)
+		}
+		if merged, ok := phx.MergeDiffs(m, next); ok {
+			m = merged
+			s.queues.coalesced.Add(1)
+			continue
+		}
+		err := s.conn.WriteMessage(websocket.TextMessage, m)
+		if err != nil {
+			return err
+		}
+		m = next
+	}
+	return s.conn.WriteMessage(websocket.TextMessage, m)
+}
+
+// WriteQueueStats are metrics of the write queues of the websockets served by a
+// WebsocketHandler; see Config.WriteQueueSize.
+type WriteQueueStats struct {
+	// Sockets is the number of websockets being served.
+	Sockets int
+	// Queued is the number of messages queued to be written, across websockets.
+	Queued int
+	// MaxDepth is the number of messages queued for the websocket furthest behind.
+	MaxDepth int
+	// SlowConsumers is the number of websockets disconnected for falling behind
+	// (see SlowConsumerPolicy) since the WebsocketHandler was created.
+	SlowConsumers int64
+	// Coalesced is the number of diffs merged into the one queued before them, rather
+	// than written on their own, since the WebsocketHandler was created.
+	Coalesced int64
+}
+
+// WriteQueueStats returns metrics of the write queues of the websockets x serves,
+// e.g. to export to a monitoring system.
+func (x *WebsocketHandler) WriteQueueStats() WriteQueueStats {
+	return x.queues.stats()
+}
+
+// writeQueues tracks the write queues of the websockets of a WebsocketHandler.
+type writeQueues struct {
+	mu            sync.Mutex
+	sockets       map[*socket]struct{}
+	slowConsumers atomic.Int64
+	coalesced     atomic.Int64
+}
+
+func newWriteQueues() *writeQueues {
+	return &writeQueues{sockets: make(map[*socket]struct{})}
+}
+
+// track tracks the queue of s, returning the function to call once s is done.
+func (q *writeQueues) track(s *socket) (untrack func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sockets[s] = struct{}{}
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		delete(q.sockets, s)
+	}
+}
+
+func (q *writeQueues) stats() WriteQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	st := WriteQueueStats{
+		Sockets:       len(q.sockets),
+		SlowConsumers: q.slowConsumers.Load(),
+		Coalesced:     q.coalesced.Load(),
+	}
+	for s := range q.sockets {
+		n := len(s.out)
+		st.Queued += n
+		st.MaxDepth = max(st.MaxDepth, n)
+	}
+	return st
+}
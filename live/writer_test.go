@@ -0,0 +1,167 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canopyclimate/golive/htmltmpl"
+	"github.com/gorilla/websocket"
+)
+
+// floodView renders a large page for every Info, sending itself another, without end,
+// once a "flood" event starts it.
+type floodView struct {
+	N          int
+	Page       string
+	terminated chan error
+}
+
+func (v *floodView) HandleEvent(ctx context.Context, e *Event) error {
+	SendInfo(ctx, &Info{Type: "flood"})
+	return nil
+}
+
+func (v *floodView) HandleInfo(ctx context.Context, info *Info) error {
+	v.N++
+	v.Page = strings.Repeat(string(rune('a'+v.N%26)), 64<<10)
+	SendInfo(ctx, &Info{Type: "flood"})
+	return nil
+}
+
+func (v *floodView) Terminate(ctx context.Context, reason error) {
+	v.terminated <- reason
+}
+
+func (v *floodView) Render(ctx context.Context, meta *Meta) (any, *htmltmpl.Template) {
+	return v, htmltmpl.Must(htmltmpl.New("flood").Parse(`<p>{{ .Page }}</p>`))
+}
+
+func TestSlowConsumer(t *testing.T) {
+	for _, policy := range []SlowConsumerPolicy{DisconnectSlowConsumer, WaitForSlowConsumer} {
+		v := &floodView{terminated: make(chan error, 1)}
+		c := newTestConfig(map[string]func() View{
+			"/flood": func() View { return v },
+		})
+		c.WriteQueueSize = 2
+		c.WriteTimeout = 100 * time.Millisecond
+		c.SlowConsumerPolicy = policy
+		x := NewWebsocketHandler(*c)
+		mux := http.NewServeMux()
+		mux.Handle("/live/websocket", x)
+		mux.Handle("/", c.Middleware(http.NotFoundHandler()))
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+
+		page := getPage(t, srv, "/flood")
+		conn := dial(t, srv)
+		if event, payload := conn.join(page); event != "phx_reply" || payload["status"] != "ok" {
+			t.Fatalf("join: got %s %v", event, payload)
+		}
+		if st := x.WriteQueueStats(); st.MaxDepth > 2 {
+			t.Errorf("policy %d: got stats %+v, want at most 2 messages queued", policy, st)
+		}
+
+		// the client stops reading, while the View keeps rendering
+		conn.send(page.topic, "event", map[string]any{"type": "click", "event": "flood", "value": map[string]any{}})
+		reason := wait(t, v.terminated, "Terminate")
+		if !errors.Is(reason, ErrDisconnected) {
+			t.Errorf("policy %d: got reason %v, want disconnected", policy, reason)
+		}
+		if policy == DisconnectSlowConsumer {
+			if !errors.Is(reason, ErrSlowConsumer) {
+				t.Errorf("got reason %v, want slow consumer", reason)
+			}
+			if st := x.WriteQueueStats(); st.SlowConsumers != 1 {
+				t.Errorf("got stats %+v, want 1 slow consumer", st)
+			}
+		}
+	}
+}
+
+func TestWriteQueueStats(t *testing.T) {
+	c := newTestConfig(map[string]func() View{
+		"/plain": func() View { return new(plainView) },
+	})
+	x := NewWebsocketHandler(*c)
+	mux := http.NewServeMux()
+	mux.Handle("/live/websocket", x)
+	mux.Handle("/", c.Middleware(http.NotFoundHandler()))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	page := getPage(t, srv, "/plain")
+	conn := dial(t, srv)
+	conn.join(page)
+	if st := x.WriteQueueStats(); st != (WriteQueueStats{Sockets: 1}) {
+		t.Errorf("got stats %+v, want 1 socket with nothing queued", st)
+	}
+	conn.conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for x.WriteQueueStats().Sockets != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("socket still tracked after closing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWriteCoalescing(t *testing.T) {
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	defer srv.Close()
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	s := &socket{
+		conn:       <-conns,
+		out:        make(chan []byte, 8),
+		done:       make(chan struct{}),
+		writerDone: make(chan struct{}),
+		queues:     newWriteQueues(),
+	}
+	// a burst of renders queued while the client was behind
+	for _, m := range []string{
+		`[null,null,"lv:1","diff",{"0":"a"}]`,
+		`[null,null,"lv:1","diff",{"1":"b"}]`,
+		`[null,null,"lv:1","diff",{"0":"c"}]`,
+		`["1","2","lv:1","phx_reply",{"response":{},"status":"ok"}]`,
+		`[null,null,"lv:1","diff",{"0":"d"}]`,
+	} {
+		s.out <- []byte(m)
+	}
+	go s.writeLoop()
+	defer close(s.done)
+
+	for _, want := range []string{
+		`[null,null,"lv:1","diff",{"0":"c","1":"b"}]`,
+		`["1","2","lv:1","phx_reply",{"response":{},"status":"ok"}]`,
+		`[null,null,"lv:1","diff",{"0":"d"}]`,
+	} {
+		client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, got, err := client.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+	if st := s.queues.stats(); st.Coalesced != 2 {
+		t.Errorf("got stats %+v, want 2 coalesced diffs", st)
+	}
+}